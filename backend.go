@@ -0,0 +1,79 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd
+
+import "errors"
+
+// ErrNoEvents is returned by an EventSource when there are no events pending
+var ErrNoEvents = errors.New("no events pending")
+
+// EventSource is where a KeyboardDevice reads its events from. Normally this is
+// an evdev device under /dev/input, but any implementation can be passed to
+// NewKeyboardDevice, for example the in-memory fake in the gokbdtest package.
+type EventSource interface {
+	// Info returns the identifying details of the device.
+	Info() DeviceInfo
+	// HasKey returns true when the device can produce the given key code.
+	HasKey(code int) bool
+	// KeysDown returns the key codes currently held down on the device.
+	KeysDown() ([]int, error)
+	// LEDsOn returns the LED codes currently lit on the device.
+	LEDsOn() ([]int, error)
+	// SetLED will turn the given LED code on or off.
+	SetLED(code int, on bool) error
+	// Repeat returns the autorepeat delay and period in milliseconds.
+	Repeat() (delay, period int, err error)
+	// SetRepeat will set the autorepeat delay and period in milliseconds.
+	SetRepeat(delay, period int) error
+	// Grab will grab (true) or ungrab (false) the device for exclusive use.
+	Grab(grab bool) error
+	// NextEvent returns the next pending event without blocking. It returns
+	// ErrNoEvents when there are none and ErrDeviceRemoved once the device
+	// has been disconnected.
+	NextEvent() (InputEvent, error)
+	// Fd returns a file descriptor that is readable (pollable) whenever
+	// NextEvent has events to return.
+	Fd() int
+	// Close frees any resources held by the device.
+	Close() error
+}
+
+// EventSink is where a VirtualKeyboardDevice writes its events to. Normally
+// this is a uinput device, but any implementation can be passed to
+// NewVirtualKeyboardWithSink, for example the recorder in the gokbdtest
+// package.
+type EventSink interface {
+	// WriteEvent sends a single event. The event time is ignored.
+	WriteEvent(ev InputEvent) error
+	// Repeat returns the autorepeat delay and period in milliseconds.
+	Repeat() (delay, period int, err error)
+	// SetRepeat will set the autorepeat delay and period in milliseconds.
+	SetRepeat(delay, period int) error
+	// NextEvent returns the next event written back to the device by the
+	// kernel (such as an LED change) without blocking. It returns ErrNoEvents
+	// when there are none.
+	NextEvent() (InputEvent, error)
+	// Fd returns a file descriptor that is readable (pollable) whenever
+	// NextEvent has events to return.
+	Fd() int
+	// Close frees any resources held by the device.
+	Close() error
+}
+
+// EventBatchSink is an EventSink that can send several events at once. A
+// VirtualKeyboardDevice writes to sinks implementing it in batches, rather
+// than one event at a time. The uinput devices created by NewVirtualKeyboard
+// write each batch with a single write(2).
+type EventBatchSink interface {
+	EventSink
+	// WriteEvents sends the events, in order. The event times are ignored.
+	WriteEvents(evs ...InputEvent) error
+}
+
+var (
+	_ EventSource    = (*evdevDevice)(nil)
+	_ EventBatchSink = (*uinputDevice)(nil)
+)
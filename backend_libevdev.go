@@ -0,0 +1,254 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+//go:build cgo && !purego
+
+package gokbd
+
+// #cgo pkg-config: libevdev
+// #include <errno.h>
+// #include <stdlib.h>
+// #include <libevdev/libevdev.h>
+// #include <libevdev/libevdev-uinput.h>
+import "C"
+import (
+	"errors"
+	"fmt"
+	"time"
+	"unsafe"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sys/unix"
+)
+
+// evdevDevice is an input device read through libevdev
+type evdevDevice struct {
+	dev       *C.struct_libevdev
+	path      string
+	fd        int
+	resyncing bool
+}
+
+func openEvdevDevice(devPath string) (*evdevDevice, error) {
+	fd, err := openInputDevice(devPath)
+	if err != nil {
+		return nil, err
+	}
+	dev := C.libevdev_new()
+	c_err := C.libevdev_set_fd(dev, C.int(fd))
+	if c_err < 0 {
+		C.libevdev_free(dev)
+		unix.Close(fd)
+		return nil, errors.New("failed to init libevdev")
+	}
+	return &evdevDevice{
+		dev:  dev,
+		path: devPath,
+		fd:   fd,
+	}, nil
+}
+
+func (d *evdevDevice) Info() DeviceInfo {
+	return DeviceInfo{
+		Name:          C.GoString(C.libevdev_get_name(d.dev)),
+		Phys:          C.GoString(C.libevdev_get_phys(d.dev)),
+		Uniq:          C.GoString(C.libevdev_get_uniq(d.dev)),
+		DevNode:       d.path,
+		SysPath:       sysPathForDevNode(d.path),
+		DriverVersion: int(C.libevdev_get_driver_version(d.dev)),
+		BusType:       BusType(C.libevdev_get_id_bustype(d.dev)),
+		Vendor:        uint16(C.libevdev_get_id_vendor(d.dev)),
+		Product:       uint16(C.libevdev_get_id_product(d.dev)),
+		Version:       uint16(C.libevdev_get_id_version(d.dev)),
+	}
+}
+
+func (d *evdevDevice) HasKey(code int) bool {
+	return C.libevdev_has_event_code(d.dev, C.EV_KEY, C.uint(code)) == 1
+}
+
+func (d *evdevDevice) KeysDown() ([]int, error) {
+	var down []int
+	for code := 0; code <= keyMax; code++ {
+		if C.libevdev_get_event_value(d.dev, C.EV_KEY, C.uint(code)) != 0 {
+			down = append(down, code)
+		}
+	}
+	return down, nil
+}
+
+func (d *evdevDevice) LEDsOn() ([]int, error) {
+	// libevdev only knows the LED state as of the last event read, so ask the
+	// kernel directly.
+	return ledsOn(d.fd)
+}
+
+func (d *evdevDevice) SetLED(code int, on bool) error {
+	value := C.enum_libevdev_led_value(C.LIBEVDEV_LED_OFF)
+	if on {
+		value = C.LIBEVDEV_LED_ON
+	}
+	if rv := C.libevdev_kernel_set_led_value(d.dev, C.uint(code), value); rv < 0 {
+		return unix.Errno(-rv)
+	}
+	return nil
+}
+
+func (d *evdevDevice) Repeat() (int, int, error) {
+	return getRepeat(d.fd)
+}
+
+func (d *evdevDevice) SetRepeat(delay, period int) error {
+	return setRepeat(d.fd, delay, period)
+}
+
+func (d *evdevDevice) Grab(grab bool) error {
+	mode := C.enum_libevdev_grab_mode(C.LIBEVDEV_UNGRAB)
+	if grab {
+		mode = C.LIBEVDEV_GRAB
+	}
+	if rv := C.libevdev_grab(d.dev, mode); rv < 0 {
+		return unix.Errno(-rv)
+	}
+	return nil
+}
+
+// NextEvent will return the next event pending on the device, or
+// ErrNoEvents if there are none. After a SYN_DROPPED event, the events needed
+// to bring the device state back in sync are returned (ending with a
+// SYN_REPORT) before normal reading continues.
+func (d *evdevDevice) NextEvent() (InputEvent, error) {
+	norm := C.enum_libevdev_read_flag(C.LIBEVDEV_READ_FLAG_NORMAL)
+	resync := C.enum_libevdev_read_flag(C.LIBEVDEV_READ_FLAG_SYNC)
+	for {
+		flag := norm
+		if d.resyncing {
+			flag = resync
+		}
+		var ev C.struct_input_event
+		switch rv := C.libevdev_next_event(d.dev, C.uint(flag), &ev); {
+		case rv == -C.ENODEV:
+			return InputEvent{}, ErrDeviceRemoved
+		case rv == -C.EAGAIN && d.resyncing:
+			log.Debug().Caller().
+				Msgf("Device %s has been resynced.", d.path)
+			d.resyncing = false
+			continue
+		case rv == -C.EAGAIN:
+			return InputEvent{}, ErrNoEvents
+		case rv == -C.EINTR:
+			continue
+		case rv == C.LIBEVDEV_READ_STATUS_SYNC && !d.resyncing:
+			log.Warn().
+				Msgf("Events were dropped on device %s, resyncing.", d.path)
+			d.resyncing = true
+		case rv < 0:
+			return InputEvent{}, fmt.Errorf("could not read event: %w", unix.Errno(-rv))
+		}
+		return InputEvent{
+			Time:  time.Unix(int64(ev.time.tv_sec), int64(ev.time.tv_usec)*int64(time.Microsecond)),
+			Type:  uint16(ev._type),
+			Code:  uint16(ev.code),
+			Value: int32(ev.value),
+		}, nil
+	}
+}
+
+func (d *evdevDevice) Fd() int {
+	return d.fd
+}
+
+func (d *evdevDevice) Close() error {
+	C.libevdev_free(d.dev)
+	return unix.Close(d.fd)
+}
+
+// uinputDevice is a virtual input device created through libevdev
+type uinputDevice struct {
+	uidev *C.struct_libevdev_uinput
+	dev   *C.struct_libevdev
+}
+
+func createUinputDevice(name string, keys, leds []int) (*uinputDevice, error) {
+	var uidev *C.struct_libevdev_uinput
+
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+	dev := C.libevdev_new()
+	C.libevdev_set_name(dev, cName)
+	// expose the relevant event types
+	C.libevdev_enable_event_type(dev, C.EV_REL)
+	C.libevdev_enable_event_type(dev, C.EV_KEY)
+	C.libevdev_enable_event_type(dev, C.EV_LED)
+	C.libevdev_enable_event_type(dev, C.EV_REP)
+	C.libevdev_enable_event_type(dev, C.EV_SYN)
+	for _, code := range keys {
+		C.libevdev_enable_event_code(dev, C.EV_KEY, C.uint(code), nil)
+	}
+	for _, code := range leds {
+		C.libevdev_enable_event_code(dev, C.EV_LED, C.uint(code), nil)
+	}
+
+	rv := C.libevdev_uinput_create_from_device(dev, C.LIBEVDEV_UINPUT_OPEN_MANAGED, &uidev)
+	if rv > 0 || uidev == nil {
+		C.libevdev_free(dev)
+		return nil, errors.New("failed to create new uinput device")
+	}
+	// LED changes written back by the kernel are read from the uinput fd,
+	// which libevdev opens in blocking mode.
+	if err := unix.SetNonblock(int(C.libevdev_uinput_get_fd(uidev)), true); err != nil {
+		C.libevdev_uinput_destroy(uidev)
+		C.libevdev_free(dev)
+		return nil, errors.New("failed to create new uinput device")
+	}
+	return &uinputDevice{
+		uidev: uidev,
+		dev:   dev,
+	}, nil
+}
+
+func (u *uinputDevice) devNode() string {
+	return C.GoString(C.libevdev_uinput_get_devnode(u.uidev))
+}
+
+func (u *uinputDevice) sysPath() string {
+	return C.GoString(C.libevdev_uinput_get_syspath(u.uidev))
+}
+
+func (u *uinputDevice) WriteEvent(ev InputEvent) error {
+	rv := C.libevdev_uinput_write_event(u.uidev, C.uint(ev.Type), C.uint(ev.Code), C.int(ev.Value))
+	if rv < 0 {
+		return unix.Errno(-rv)
+	}
+	return nil
+}
+
+// WriteEvents writes the events directly to the uinput file descriptor, as
+// libevdev has no way to write more than one event at a time
+func (u *uinputDevice) WriteEvents(evs ...InputEvent) error {
+	return writeEvents(u.Fd(), evs...)
+}
+
+func (u *uinputDevice) Repeat() (int, int, error) {
+	return getDevNodeRepeat(u.devNode())
+}
+
+func (u *uinputDevice) SetRepeat(delay, period int) error {
+	return setDevNodeRepeat(u.devNode(), delay, period)
+}
+
+func (u *uinputDevice) NextEvent() (InputEvent, error) {
+	return readEvent(u.Fd())
+}
+
+func (u *uinputDevice) Fd() int {
+	return int(C.libevdev_uinput_get_fd(u.uidev))
+}
+
+func (u *uinputDevice) Close() error {
+	C.libevdev_uinput_destroy(u.uidev)
+	C.libevdev_free(u.dev)
+	return nil
+}
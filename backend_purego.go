@@ -0,0 +1,432 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+//go:build !cgo || purego
+
+package gokbd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+	"unsafe"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sys/unix"
+)
+
+// evdev ioctls, see include/uapi/linux/input.h
+func eviocgversion() uintptr       { return ioc(iocRead, 'E', 0x01, 4) }
+func eviocgid() uintptr            { return ioc(iocRead, 'E', 0x02, 8) }
+func eviocgname(n uintptr) uintptr { return ioc(iocRead, 'E', 0x06, n) }
+func eviocgphys(n uintptr) uintptr { return ioc(iocRead, 'E', 0x07, n) }
+func eviocguniq(n uintptr) uintptr { return ioc(iocRead, 'E', 0x08, n) }
+func eviocgkey(n uintptr) uintptr  { return ioc(iocRead, 'E', 0x18, n) }
+func eviocgbit(ev, n uintptr) uintptr {
+	return ioc(iocRead, 'E', 0x20+ev, n)
+}
+func eviocgrab() uintptr { return ioc(iocWrite, 'E', 0x90, 4) }
+
+// uinput ioctls, see include/uapi/linux/uinput.h
+const uinputMaxNameSize = 80
+
+func uiDevCreate() uintptr           { return ioc(iocNone, 'U', 1, 0) }
+func uiDevDestroy() uintptr          { return ioc(iocNone, 'U', 2, 0) }
+func uiDevSetup() uintptr            { return ioc(iocWrite, 'U', 3, unsafe.Sizeof(uinputSetup{})) }
+func uiSetEvBit() uintptr            { return ioc(iocWrite, 'U', 100, 4) }
+func uiSetKeyBit() uintptr           { return ioc(iocWrite, 'U', 101, 4) }
+func uiSetLedBit() uintptr           { return ioc(iocWrite, 'U', 105, 4) }
+func uiGetSysname(n uintptr) uintptr { return ioc(iocRead, 'U', 44, n) }
+
+// inputID is struct input_id
+type inputID struct {
+	BusType uint16
+	Vendor  uint16
+	Product uint16
+	Version uint16
+}
+
+// uinputSetup is struct uinput_setup
+type uinputSetup struct {
+	ID           inputID
+	Name         [uinputMaxNameSize]byte
+	FFEffectsMax uint32
+}
+
+// keyBits is a bitmask indexed by key code
+type keyBits [keyCnt / 8]byte
+
+func (b *keyBits) isSet(code int) bool {
+	if code < 0 || code > keyMax {
+		return false
+	}
+	return b[code/8]&(1<<(code%8)) != 0
+}
+
+func (b *keyBits) set(code int, on bool) {
+	if code < 0 || code > keyMax {
+		return
+	}
+	if on {
+		b[code/8] |= 1 << (code % 8)
+	} else {
+		b[code/8] &^= 1 << (code % 8)
+	}
+}
+
+// codes returns the key codes that are set
+func (b *keyBits) codes() []int {
+	var codes []int
+	for code := 0; code <= keyMax; code++ {
+		if b.isSet(code) {
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+// ledBits is a bitmask indexed by LED code
+type ledBits [ledCnt / 8]byte
+
+func (b *ledBits) isSet(code int) bool {
+	if code < 0 || code > ledMax {
+		return false
+	}
+	return b[code/8]&(1<<(code%8)) != 0
+}
+
+func (b *ledBits) set(code int, on bool) {
+	if code < 0 || code > ledMax {
+		return
+	}
+	if on {
+		b[code/8] |= 1 << (code % 8)
+	} else {
+		b[code/8] &^= 1 << (code % 8)
+	}
+}
+
+// evdevDevice is an input device read directly through the evdev interface
+type evdevDevice struct {
+	path      string
+	fd        int
+	keys      keyBits
+	state     keyBits
+	ledState  ledBits
+	queue     []InputEvent
+	buf       []byte
+	resyncing bool
+}
+
+func openEvdevDevice(devPath string) (*evdevDevice, error) {
+	fd, err := openInputDevice(devPath)
+	if err != nil {
+		return nil, err
+	}
+	d := &evdevDevice{
+		path: devPath,
+		fd:   fd,
+		buf:  make([]byte, 64*sizeofRawInputEvent),
+	}
+	if err := ioctlPtr(fd, eviocgbit(evKey, unsafe.Sizeof(d.keys)), unsafe.Pointer(&d.keys)); err != nil {
+		unix.Close(fd)
+		return nil, errors.New("failed to init evdev device")
+	}
+	if err := ioctlPtr(fd, eviocgkey(unsafe.Sizeof(d.state)), unsafe.Pointer(&d.state)); err != nil {
+		unix.Close(fd)
+		return nil, errors.New("failed to init evdev device")
+	}
+	if err := ioctlPtr(fd, eviocgled(unsafe.Sizeof(d.ledState)), unsafe.Pointer(&d.ledState)); err != nil {
+		log.Debug().Caller().Err(err).
+			Msgf("Could not fetch LED state of device %s.", devPath)
+	}
+	return d, nil
+}
+
+func (d *evdevDevice) ioctlString(req func(uintptr) uintptr) string {
+	buf := make([]byte, 256)
+	if err := ioctlPtr(d.fd, req(uintptr(len(buf))), unsafe.Pointer(&buf[0])); err != nil {
+		return ""
+	}
+	return cString(buf)
+}
+
+func (d *evdevDevice) Info() DeviceInfo {
+	var id inputID
+	var version int32
+	_ = ioctlPtr(d.fd, eviocgid(), unsafe.Pointer(&id))
+	_ = ioctlPtr(d.fd, eviocgversion(), unsafe.Pointer(&version))
+	return DeviceInfo{
+		Name:          d.ioctlString(eviocgname),
+		Phys:          d.ioctlString(eviocgphys),
+		Uniq:          d.ioctlString(eviocguniq),
+		DevNode:       d.path,
+		SysPath:       sysPathForDevNode(d.path),
+		DriverVersion: int(version),
+		BusType:       BusType(id.BusType),
+		Vendor:        id.Vendor,
+		Product:       id.Product,
+		Version:       id.Version,
+	}
+}
+
+func (d *evdevDevice) HasKey(code int) bool {
+	return d.keys.isSet(code)
+}
+
+func (d *evdevDevice) KeysDown() ([]int, error) {
+	var current keyBits
+	if err := ioctlPtr(d.fd, eviocgkey(unsafe.Sizeof(current)), unsafe.Pointer(&current)); err != nil {
+		return nil, err
+	}
+	return current.codes(), nil
+}
+
+func (d *evdevDevice) LEDsOn() ([]int, error) {
+	return ledsOn(d.fd)
+}
+
+func (d *evdevDevice) SetLED(code int, on bool) error {
+	return writeEvents(d.fd,
+		InputEvent{Type: evLed, Code: uint16(code), Value: boolValue(on)},
+		InputEvent{Type: evSyn, Code: synReport},
+	)
+}
+
+func (d *evdevDevice) Repeat() (int, int, error) {
+	return getRepeat(d.fd)
+}
+
+func (d *evdevDevice) SetRepeat(delay, period int) error {
+	return setRepeat(d.fd, delay, period)
+}
+
+func (d *evdevDevice) Grab(grab bool) error {
+	var mode uintptr
+	if grab {
+		mode = 1
+	}
+	return ioctlVal(d.fd, eviocgrab(), mode)
+}
+
+// NextEvent will return the next event pending on the device, or
+// ErrNoEvents if there are none. After a SYN_DROPPED event, the events needed
+// to bring the device state back in sync are returned (ending with a
+// SYN_REPORT) before normal reading continues.
+func (d *evdevDevice) NextEvent() (InputEvent, error) {
+	for len(d.queue) == 0 {
+		if err := d.fill(); err != nil {
+			return InputEvent{}, err
+		}
+	}
+	ev := d.queue[0]
+	d.queue = d.queue[1:]
+	return ev, nil
+}
+
+// fill will read any pending events from the device into the queue
+func (d *evdevDevice) fill() error {
+	n, err := unix.Read(d.fd, d.buf)
+	switch {
+	case errors.Is(err, unix.EINTR):
+		return nil
+	case errors.Is(err, unix.EAGAIN):
+		return ErrNoEvents
+	case errors.Is(err, unix.ENODEV):
+		return ErrDeviceRemoved
+	case err != nil:
+		return fmt.Errorf("could not read event: %w", err)
+	case n == 0:
+		return ErrDeviceRemoved
+	}
+	for off := 0; off+sizeofRawInputEvent <= n; off += sizeofRawInputEvent {
+		raw := (*rawInputEvent)(unsafe.Pointer(&d.buf[off]))
+		ev := raw.inputEvent()
+		switch {
+		case ev.Type == evSyn && ev.Code == synDropped:
+			log.Warn().
+				Msgf("Events were dropped on device %s, resyncing.", d.path)
+			d.queue = append(d.queue, ev)
+			d.resyncing = true
+		case d.resyncing && ev.Type == evSyn && ev.Code == synReport:
+			// Everything up to this point is incomplete, replace it with the
+			// difference between the last known and current key and LED
+			// state.
+			d.resync(ev.Time)
+			d.resyncing = false
+			log.Debug().Caller().
+				Msgf("Device %s has been resynced.", d.path)
+		case d.resyncing:
+		default:
+			switch ev.Type {
+			case evKey:
+				d.state.set(int(ev.Code), ev.Value != 0)
+			case evLed:
+				d.ledState.set(int(ev.Code), ev.Value != 0)
+			}
+			d.queue = append(d.queue, ev)
+		}
+	}
+	return nil
+}
+
+// resync will queue key and LED events for any keys and LEDs whose state
+// changed while events were being dropped, followed by a SYN_REPORT
+func (d *evdevDevice) resync(ts time.Time) {
+	current := d.state
+	if err := ioctlPtr(d.fd, eviocgkey(unsafe.Sizeof(current)), unsafe.Pointer(&current)); err != nil {
+		log.Debug().Caller().Err(err).
+			Msgf("Could not fetch key state of device %s.", d.path)
+		current = d.state
+	}
+	currentLEDs := d.ledState
+	if err := ioctlPtr(d.fd, eviocgled(unsafe.Sizeof(currentLEDs)), unsafe.Pointer(&currentLEDs)); err != nil {
+		log.Debug().Caller().Err(err).
+			Msgf("Could not fetch LED state of device %s.", d.path)
+		currentLEDs = d.ledState
+	}
+	d.syncTo(ts, current, currentLEDs)
+}
+
+// syncTo will queue the events to bring the last known key and LED state to
+// the given state, followed by a SYN_REPORT
+func (d *evdevDevice) syncTo(ts time.Time, keys keyBits, leds ledBits) {
+	for code := 0; code <= keyMax; code++ {
+		if was, is := d.state.isSet(code), keys.isSet(code); was != is {
+			d.queue = append(d.queue, InputEvent{Time: ts, Type: evKey, Code: uint16(code), Value: boolValue(is)})
+		}
+	}
+	for code := 0; code <= ledMax; code++ {
+		if was, is := d.ledState.isSet(code), leds.isSet(code); was != is {
+			d.queue = append(d.queue, InputEvent{Time: ts, Type: evLed, Code: uint16(code), Value: boolValue(is)})
+		}
+	}
+	d.state = keys
+	d.ledState = leds
+	d.queue = append(d.queue, InputEvent{Time: ts, Type: evSyn, Code: synReport})
+}
+
+// boolValue returns the event value for a key or LED that is on (1) or off (0)
+func boolValue(on bool) int32 {
+	if on {
+		return 1
+	}
+	return 0
+}
+
+func (d *evdevDevice) Fd() int {
+	return d.fd
+}
+
+func (d *evdevDevice) Close() error {
+	return unix.Close(d.fd)
+}
+
+// uinputDevice is a virtual input device created through /dev/uinput
+type uinputDevice struct {
+	fd      int
+	sysname string
+}
+
+func createUinputDevice(name string, keys, leds []int) (*uinputDevice, error) {
+	fd, err := unix.Open("/dev/uinput", unix.O_RDWR|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, errors.New("failed to create new uinput device")
+	}
+	fail := func() (*uinputDevice, error) {
+		unix.Close(fd)
+		return nil, errors.New("failed to create new uinput device")
+	}
+	// expose the relevant event types
+	for _, ev := range []uintptr{evRel, evKey, evLed, evRep, evSyn} {
+		if err := ioctlVal(fd, uiSetEvBit(), ev); err != nil {
+			return fail()
+		}
+	}
+	for _, code := range keys {
+		if err := ioctlVal(fd, uiSetKeyBit(), uintptr(code)); err != nil {
+			return fail()
+		}
+	}
+	for _, code := range leds {
+		if err := ioctlVal(fd, uiSetLedBit(), uintptr(code)); err != nil {
+			return fail()
+		}
+	}
+	var setup uinputSetup
+	copy(setup.Name[:uinputMaxNameSize-1], name)
+	if err := ioctlPtr(fd, uiDevSetup(), unsafe.Pointer(&setup)); err != nil {
+		return fail()
+	}
+	if err := ioctlVal(fd, uiDevCreate(), 0); err != nil {
+		return fail()
+	}
+	buf := make([]byte, 64)
+	if err := ioctlPtr(fd, uiGetSysname(uintptr(len(buf))), unsafe.Pointer(&buf[0])); err != nil {
+		_ = ioctlVal(fd, uiDevDestroy(), 0)
+		return fail()
+	}
+	return &uinputDevice{
+		fd:      fd,
+		sysname: cString(buf),
+	}, nil
+}
+
+func (u *uinputDevice) devNode() string {
+	entries, err := os.ReadDir(u.sysPath())
+	if err != nil {
+		return ""
+	}
+	for _, e := range entries {
+		if eventDeviceRegexp.MatchString(e.Name()) {
+			return filepath.Join(devicePath, e.Name())
+		}
+	}
+	return ""
+}
+
+func (u *uinputDevice) sysPath() string {
+	return filepath.Join("/sys/devices/virtual/input", u.sysname)
+}
+
+func (u *uinputDevice) WriteEvent(ev InputEvent) error {
+	return writeEvents(u.fd, ev)
+}
+
+func (u *uinputDevice) WriteEvents(evs ...InputEvent) error {
+	return writeEvents(u.fd, evs...)
+}
+
+func (u *uinputDevice) Repeat() (int, int, error) {
+	return getDevNodeRepeat(u.devNode())
+}
+
+func (u *uinputDevice) SetRepeat(delay, period int) error {
+	return setDevNodeRepeat(u.devNode(), delay, period)
+}
+
+func (u *uinputDevice) NextEvent() (InputEvent, error) {
+	return readEvent(u.fd)
+}
+
+func (u *uinputDevice) Fd() int {
+	return u.fd
+}
+
+func (u *uinputDevice) Close() error {
+	_ = ioctlVal(u.fd, uiDevDestroy(), 0)
+	return unix.Close(u.fd)
+}
+
+// cString will return the contents of a NUL terminated byte buffer
+func cString(buf []byte) string {
+	if i := bytes.IndexByte(buf, 0); i >= 0 {
+		buf = buf[:i]
+	}
+	return string(buf)
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+//go:build !cgo || purego
+
+package gokbd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ioctlNumbers(t *testing.T) {
+	tests := []struct {
+		name string
+		got  uintptr
+		want uintptr
+	}{
+		{name: "EVIOCGVERSION", got: eviocgversion(), want: 0x80044501},
+		{name: "EVIOCGID", got: eviocgid(), want: 0x80084502},
+		{name: "EVIOCGNAME(256)", got: eviocgname(256), want: 0x81004506},
+		{name: "EVIOCGKEY(96)", got: eviocgkey(96), want: 0x80604518},
+		{name: "EVIOCGBIT(EV_KEY, 96)", got: eviocgbit(evKey, 96), want: 0x80604521},
+		{name: "EVIOCGRAB", got: eviocgrab(), want: 0x40044590},
+		{name: "UI_DEV_CREATE", got: uiDevCreate(), want: 0x5501},
+		{name: "UI_DEV_DESTROY", got: uiDevDestroy(), want: 0x5502},
+		{name: "UI_DEV_SETUP", got: uiDevSetup(), want: 0x405c5503},
+		{name: "UI_SET_EVBIT", got: uiSetEvBit(), want: 0x40045564},
+		{name: "UI_SET_KEYBIT", got: uiSetKeyBit(), want: 0x40045565},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.got)
+		})
+	}
+}
+
+func Test_keyBits(t *testing.T) {
+	var b keyBits
+	assert.False(t, b.isSet(keyCapsLock))
+	b.set(keyCapsLock, true)
+	assert.True(t, b.isSet(keyCapsLock))
+	assert.False(t, b.isSet(keyCapsLock+1))
+	b.set(keyCapsLock, false)
+	assert.False(t, b.isSet(keyCapsLock))
+	b.set(keyMax+1, true)
+	assert.False(t, b.isSet(keyMax+1))
+}
+
+func Test_cString(t *testing.T) {
+	assert.Equal(t, "input12", cString([]byte("input12\x00\x00garbage")))
+	assert.Equal(t, "noterm", cString([]byte("noterm")))
+}
+
+func Test_ledBits(t *testing.T) {
+	var b ledBits
+	assert.False(t, b.isSet(ledCapsL))
+	b.set(ledCapsL, true)
+	assert.True(t, b.isSet(ledCapsL))
+	assert.False(t, b.isSet(ledNumL))
+	b.set(ledCapsL, false)
+	assert.False(t, b.isSet(ledCapsL))
+	b.set(ledMax+1, true)
+	assert.False(t, b.isSet(ledMax+1))
+}
+
+func Test_evdevDevice_syncTo(t *testing.T) {
+	ts := time.Unix(1, 0)
+	state := func(codes ...int) keyBits {
+		var b keyBits
+		for _, c := range codes {
+			b.set(c, true)
+		}
+		return b
+	}
+	leds := func(codes ...int) ledBits {
+		var b ledBits
+		for _, c := range codes {
+			b.set(c, true)
+		}
+		return b
+	}
+	tests := []struct {
+		name       string
+		keys, now  keyBits
+		ledState   ledBits
+		ledsNow    ledBits
+		wantEvents []InputEvent
+	}{
+		{
+			name: "no changes",
+			keys: state(keyLeftShift),
+			now:  state(keyLeftShift),
+			wantEvents: []InputEvent{
+				{Time: ts, Type: evSyn, Code: synReport},
+			},
+		},
+		{
+			name: "dropped key events",
+			keys: state(keyLeftShift),
+			now:  state(keyCapsLock),
+			wantEvents: []InputEvent{
+				{Time: ts, Type: evKey, Code: keyLeftShift, Value: 0},
+				{Time: ts, Type: evKey, Code: keyCapsLock, Value: 1},
+				{Time: ts, Type: evSyn, Code: synReport},
+			},
+		},
+		{
+			name:     "dropped LED events",
+			ledState: leds(ledNumL),
+			ledsNow:  leds(ledCapsL),
+			wantEvents: []InputEvent{
+				{Time: ts, Type: evLed, Code: ledNumL, Value: 0},
+				{Time: ts, Type: evLed, Code: ledCapsL, Value: 1},
+				{Time: ts, Type: evSyn, Code: synReport},
+			},
+		},
+		{
+			name:    "dropped key and LED events",
+			now:     state(keyCapsLock),
+			ledsNow: leds(ledCapsL),
+			wantEvents: []InputEvent{
+				{Time: ts, Type: evKey, Code: keyCapsLock, Value: 1},
+				{Time: ts, Type: evLed, Code: ledCapsL, Value: 1},
+				{Time: ts, Type: evSyn, Code: synReport},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &evdevDevice{state: tt.keys, ledState: tt.ledState}
+			d.syncTo(ts, tt.now, tt.ledsNow)
+			assert.Equal(t, tt.wantEvents, d.queue)
+			assert.Equal(t, tt.now, d.state)
+			assert.Equal(t, tt.ledsNow, d.ledState)
+		})
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// JitterDistribution is how the random variation of a TypingProfile's timings
+// is distributed
+type JitterDistribution int
+
+const (
+	// JitterUniform varies each timing by up to the jitter either way, with
+	// every amount equally likely.
+	JitterUniform JitterDistribution = iota
+	// JitterNormal varies each timing with a normal distribution, with the
+	// jitter as its standard deviation. Small variations are more likely
+	// than large ones, as for a person typing.
+	JitterNormal
+)
+
+func (d JitterDistribution) String() string {
+	switch d {
+	case JitterUniform:
+		return "JitterUniform"
+	case JitterNormal:
+		return "JitterNormal"
+	default:
+		return fmt.Sprintf("JitterDistribution(%d)", int(d))
+	}
+}
+
+// TypingProfile controls the cadence of the keys typed by a virtual keyboard,
+// see WithTypingProfile and SetTypingProfile. The zero value types as fast as
+// possible. Keys held with Press are not paced.
+type TypingProfile struct {
+	// Dwell is how long each key is held down before it is released. When
+	// modifiers are held, each is held down for Dwell before the next key is
+	// pressed.
+	Dwell time.Duration
+	// Delay is the pause after each key stroke, before the next key is
+	// pressed.
+	Delay time.Duration
+	// CPM is a target speed in characters per minute. If set, it is used in
+	// place of Delay, which becomes whatever is left of each character's time
+	// after Dwell.
+	CPM int
+	// Jitter is how much Dwell and Delay vary at random, according to
+	// Distribution. Timings never go below zero.
+	Jitter time.Duration
+	// Distribution is how the jitter is distributed.
+	Distribution JitterDistribution
+	// WordPause is an extra pause after each space typed by TypeString.
+	WordPause time.Duration
+}
+
+// delay returns the pause after each key stroke, without jitter
+func (p TypingProfile) delay() time.Duration {
+	if p.CPM > 0 {
+		perChar := time.Minute / time.Duration(p.CPM)
+		if perChar > p.Dwell {
+			return perChar - p.Dwell
+		}
+		return 0
+	}
+	return p.Delay
+}
+
+// vary returns the duration with jitter added
+func (p TypingProfile) vary(d time.Duration) time.Duration {
+	if p.Jitter > 0 {
+		switch p.Distribution {
+		case JitterNormal:
+			d += time.Duration(rand.NormFloat64() * float64(p.Jitter))
+		default:
+			d += time.Duration((rand.Float64()*2 - 1) * float64(p.Jitter))
+		}
+	}
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// pace returns a copy of the key events with the pause after each set. Keys
+// are held for Dwell after the SYN_REPORT that follows their press, and each
+// key stroke is followed by Delay once its keys are all released (after the
+// SYN_REPORT that follows the last release, before the next press).
+func (p TypingProfile) pace(keys []*key) []*key {
+	if p == (TypingProfile{}) {
+		return keys
+	}
+	delay := p.delay()
+	paced := make([]*key, len(keys))
+	var pressed bool
+	for i, k := range keys {
+		paced[i] = &key{keyType: k.keyType, keyCode: k.keyCode, value: k.value}
+		switch {
+		case k.keyType == evKey:
+			pressed = k.value == 1
+		case k.keyType == evSyn && pressed:
+			paced[i].delay = p.vary(p.Dwell)
+		case k.keyType == evSyn && nextKeyPressed(keys[i+1:]):
+			paced[i].delay = p.vary(delay)
+		}
+	}
+	return paced
+}
+
+// pause will wait for the duration, or until the context is cancelled
+func pause(ctx context.Context, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// nextKeyPressed returns whether the next key event is a press, or there are
+// no more key events
+func nextKeyPressed(keys []*key) bool {
+	for _, k := range keys {
+		if k.keyType == evKey {
+			return k.value == 1
+		}
+	}
+	return true
+}
+
+// WithTypingProfile will set the cadence of the keys typed by the virtual
+// keyboard
+func WithTypingProfile(p TypingProfile) VirtualKeyboardOption {
+	return func(o *virtualKeyboardOptions) {
+		o.profile = p
+	}
+}
+
+// SetTypingProfile sets the cadence of the keys typed by the virtual keyboard
+func (u *VirtualKeyboardDevice) SetTypingProfile(p TypingProfile) {
+	u.typingMu.Lock()
+	defer u.typingMu.Unlock()
+	u.typing.profile = p
+}
+
+// TypingProfile returns the cadence of the keys typed by the virtual keyboard
+func (u *VirtualKeyboardDevice) TypingProfile() TypingProfile {
+	return u.typingOptions().profile
+}
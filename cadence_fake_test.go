@@ -0,0 +1,55 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/joshuar/gokbd"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVirtualKeyboardDevice_TypingProfile(t *testing.T) {
+	const (
+		dwell = 20 * time.Millisecond
+		pause = 50 * time.Millisecond
+	)
+	v, sink := newVirtualKeyboard(t,
+		gokbd.WithTypingProfile(gokbd.TypingProfile{Dwell: dwell, CPM: 1200, WordPause: pause}))
+	assert.Equal(t, 1200, v.TypingProfile().CPM)
+
+	assert.Nil(t, v.TypeString("a b"))
+	var presses, releases []time.Time
+	for _, ev := range sink.Events() {
+		switch {
+		case ev.Type == uint16(gokbd.EventKey) && ev.Value == 1:
+			presses = append(presses, ev.Time)
+		case ev.Type == uint16(gokbd.EventKey) && ev.Value == 0:
+			releases = append(releases, ev.Time)
+		}
+	}
+	if !assert.Len(t, presses, 3) || !assert.Len(t, releases, 3) {
+		return
+	}
+	for i := range presses {
+		// each key is held for the dwell time
+		assert.GreaterOrEqual(t, releases[i].Sub(presses[i]), dwell)
+	}
+	// 1200 CPM is 50ms a character
+	assert.GreaterOrEqual(t, presses[1].Sub(presses[0]), 50*time.Millisecond)
+	// with a pause after the space
+	assert.GreaterOrEqual(t, presses[2].Sub(presses[1]), 50*time.Millisecond+pause)
+
+	// the profile can be changed, and is used for chords
+	sink.Reset()
+	v.SetTypingProfile(gokbd.TypingProfile{Dwell: dwell})
+	assert.Nil(t, v.TypeChord(gokbd.KeyLeftCtrl, gokbd.KeyA))
+	events := sink.Events()
+	if assert.Len(t, events, 8) {
+		assert.GreaterOrEqual(t, events[4].Time.Sub(events[0].Time), 2*dwell)
+	}
+}
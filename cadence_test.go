@@ -0,0 +1,87 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJitterDistribution_String(t *testing.T) {
+	assert.Equal(t, "JitterUniform", JitterUniform.String())
+	assert.Equal(t, "JitterNormal", JitterNormal.String())
+	assert.Equal(t, "JitterDistribution(5)", JitterDistribution(5).String())
+}
+
+func TestTypingProfile_delay(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile TypingProfile
+		want    time.Duration
+	}{
+		{name: "zero"},
+		{name: "delay", profile: TypingProfile{Delay: 50 * time.Millisecond}, want: 50 * time.Millisecond},
+		{name: "cpm", profile: TypingProfile{CPM: 300, Delay: time.Second}, want: 200 * time.Millisecond},
+		{name: "cpm with dwell", profile: TypingProfile{CPM: 300, Dwell: 50 * time.Millisecond}, want: 150 * time.Millisecond},
+		{name: "cpm too fast for dwell", profile: TypingProfile{CPM: 6000, Dwell: 50 * time.Millisecond}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.profile.delay())
+		})
+	}
+}
+
+func TestTypingProfile_vary(t *testing.T) {
+	d := 100 * time.Millisecond
+	assert.Equal(t, d, TypingProfile{}.vary(d))
+	assert.Equal(t, time.Duration(0), TypingProfile{}.vary(-d))
+
+	uniform := TypingProfile{Jitter: 20 * time.Millisecond}
+	normal := TypingProfile{Jitter: 20 * time.Millisecond, Distribution: JitterNormal}
+	var varied bool
+	for i := 0; i < 1000; i++ {
+		got := uniform.vary(d)
+		assert.GreaterOrEqual(t, got, 80*time.Millisecond)
+		assert.LessOrEqual(t, got, 120*time.Millisecond)
+		varied = varied || got != d
+		assert.GreaterOrEqual(t, normal.vary(10*time.Millisecond), time.Duration(0))
+	}
+	assert.True(t, varied)
+}
+
+func TestTypingProfile_pace(t *testing.T) {
+	const (
+		dwell = 10 * time.Millisecond
+		delay = 30 * time.Millisecond
+	)
+	profile := TypingProfile{Dwell: dwell, Delay: delay}
+	keys := append(chordKeys(30, keyLeftShift), chordKeys(48)...)
+	got := profile.pace(keys)
+	var delays []time.Duration
+	for i, k := range got {
+		assert.Equal(t, keys[i].keyType, k.keyType)
+		assert.Equal(t, keys[i].keyCode, k.keyCode)
+		assert.Equal(t, keys[i].value, k.value)
+		delays = append(delays, k.delay)
+	}
+	assert.Equal(t, []time.Duration{
+		0, dwell, // shift down
+		0, dwell, // a down
+		0, 0, // a up
+		0, delay, // shift up
+		0, dwell, // b down
+		0, delay, // b up
+	}, delays)
+	// The keys passed in are untouched.
+	for _, k := range keys {
+		assert.Zero(t, k.delay)
+	}
+	// The zero profile leaves the keys as they are.
+	assert.Equal(t, keys, TypingProfile{}.pace(keys))
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd
+
+// Event types and codes used internally, see linux/input-event-codes.h
+const (
+	evSyn = 0x00
+	evKey = 0x01
+	evRel = 0x02
+	evLed = 0x11
+	evRep = 0x14
+
+	synReport  = 0
+	synDropped = 3
+
+	keyBackspace  = 14
+	keyU          = 22
+	keyLeftCtrl   = 29
+	keyLeftShift  = 42
+	keyRightShift = 54
+	keyLeftAlt    = 56
+	keySpace      = 57
+	keyCapsLock   = 58
+	keyNumLock    = 69
+	keyScrollLock = 70
+	keyRightCtrl  = 97
+	keyRightAlt   = 100
+	keyLeftMeta   = 125
+	keyRightMeta  = 126
+	keyCompose    = 127
+	keyF24        = 194
+	keyMax        = 0x2ff
+	keyCnt        = keyMax + 1
+
+	ledNumL    = 0x00
+	ledCapsL   = 0x01
+	ledScrollL = 0x02
+	ledCompose = 0x03
+	ledKana    = 0x04
+	ledMax     = 0x0f
+	ledCnt     = ledMax + 1
+)
+
+// eventTypeName returns the name of the given event type, for example EV_KEY,
+// or an empty string if the type is unknown
+func eventTypeName(evType uint16) string {
+	return eventTypeNames[evType]
+}
+
+// eventCodeName returns the name of the given event code, for example KEY_A,
+// or an empty string if the code is unknown
+func eventCodeName(evType, code uint16) string {
+	return eventCodeNames[evType][code]
+}
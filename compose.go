@@ -0,0 +1,533 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// DefaultComposeDir is where the X11 locale data, including the Compose file
+// for each locale, is usually installed
+const DefaultComposeDir = "/usr/share/X11/locale"
+
+// defaultComposeLocale is the locale used when none is set in the environment
+const defaultComposeLocale = "en_US.UTF-8"
+
+// composeMaxIncludeDepth limits how deeply includes are followed, to catch
+// loops
+const composeMaxIncludeDepth = 8
+
+// ComposeTable holds the sequences of dead keys and Compose key (Multi_key)
+// presses defined in an X11 Compose file, and the text each produces. Use it
+// with WithCompose to have a Snooper pass out the composed characters.
+type ComposeTable struct {
+	root        *composeNode
+	reverse     map[string][][]composeSym
+	reverseOnce sync.Once
+}
+
+// composeNode is a step through the sequences in a ComposeTable. Nodes with no
+// next step complete a sequence and produce their text.
+type composeNode struct {
+	next map[composeSym]*composeNode
+	text string
+}
+
+// composeSym is a keysym in a compose sequence: either the character it
+// produces or, for dead keys and Multi_key, its name
+type composeSym struct {
+	r    rune
+	name string
+}
+
+// isDeadKeysym returns whether the keysym is a dead key or the Compose key,
+// those that start a compose sequence
+func isDeadKeysym(sym string) bool {
+	return strings.HasPrefix(sym, "dead_") || sym == "Multi_key"
+}
+
+// LoadComposeTable will load a Compose file, such as
+// /usr/share/X11/locale/en_US.UTF-8/Compose or ~/.XCompose, along with any
+// files it includes. Files included with relative paths are loaded from the
+// directory of the file including them.
+func LoadComposeTable(path string) (*ComposeTable, error) {
+	l := newComposeLoader("")
+	if err := l.load(path, 0); err != nil {
+		return nil, err
+	}
+	return l.table, nil
+}
+
+// LoadLocaleComposeTable will load the system Compose file for the given
+// locale, for example "en_US.UTF-8", as listed in the compose.dir file under
+// DefaultComposeDir. If locale is empty, the LC_ALL, LC_CTYPE or LANG
+// environment variable is used.
+func LoadLocaleComposeTable(locale string) (*ComposeTable, error) {
+	l := newComposeLoader(locale)
+	path, err := l.localeFile()
+	if err != nil {
+		return nil, err
+	}
+	if err := l.load(path, 0); err != nil {
+		return nil, err
+	}
+	return l.table, nil
+}
+
+// ParseCompose will read compose sequences in the X11 Compose file format.
+// Files included with relative paths are loaded from the current directory.
+func ParseCompose(r io.Reader) (*ComposeTable, error) {
+	l := newComposeLoader("")
+	if err := l.parse(r, "", 0); err != nil {
+		return nil, err
+	}
+	return l.table, nil
+}
+
+// lookup returns the text produced by the sequence of keysyms, or false if it
+// is not a complete sequence in the table
+func (t *ComposeTable) lookup(syms ...string) (string, bool) {
+	n := t.root
+	for _, sym := range syms {
+		s, ok := parseComposeSym(sym)
+		if !ok {
+			return "", false
+		}
+		if n = n.next[s]; n == nil {
+			return "", false
+		}
+	}
+	return n.text, n != t.root && len(n.next) == 0
+}
+
+// sequences returns the sequences in the table that produce the text,
+// shortest first
+func (t *ComposeTable) sequences(text string) [][]composeSym {
+	t.reverseOnce.Do(func() {
+		t.reverse = make(map[string][][]composeSym)
+		var walk func(n *composeNode, seq []composeSym)
+		walk = func(n *composeNode, seq []composeSym) {
+			if len(n.next) == 0 && len(seq) > 0 {
+				t.reverse[n.text] = append(t.reverse[n.text], append([]composeSym(nil), seq...))
+				return
+			}
+			for sym, next := range n.next {
+				walk(next, append(seq, sym))
+			}
+		}
+		walk(t.root, nil)
+		for _, seqs := range t.reverse {
+			sort.Slice(seqs, func(i, j int) bool {
+				return lessComposeSeq(seqs[i], seqs[j])
+			})
+		}
+	})
+	return t.reverse[text]
+}
+
+// lessComposeSeq orders sequences by length and then by their keysyms, so the
+// choice of sequence is stable
+func lessComposeSeq(a, b []composeSym) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	for i := range a {
+		switch {
+		case a[i].name != b[i].name:
+			return a[i].name < b[i].name
+		case a[i].r != b[i].r:
+			return a[i].r < b[i].r
+		}
+	}
+	return false
+}
+
+// add will add a sequence to the table. As with libX11, a sequence replaces
+// any that it is a prefix of or that are a prefix of it.
+func (t *ComposeTable) add(seq []composeSym, text string) {
+	n := t.root
+	for _, s := range seq {
+		next := n.next[s]
+		if next == nil {
+			next = &composeNode{}
+			if n.next == nil {
+				n.next = make(map[composeSym]*composeNode)
+			}
+			n.next[s] = next
+		}
+		n.text = ""
+		n = next
+	}
+	n.next = nil
+	n.text = text
+}
+
+// parseComposeSym returns the compose step for a keysym, or false if the
+// keysym produces no character and is not a dead key
+func parseComposeSym(sym string) (composeSym, bool) {
+	if r := keysymRune(sym); r != 0 {
+		return composeSym{r: r}, true
+	}
+	if isDeadKeysym(sym) {
+		return composeSym{name: sym}, true
+	}
+	return composeSym{}, false
+}
+
+// composeLoader loads Compose files into a table, expanding the include
+// substitutions as libX11 does
+type composeLoader struct {
+	table  *ComposeTable
+	locale string
+}
+
+func newComposeLoader(locale string) *composeLoader {
+	if locale == "" {
+		for _, env := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+			if locale = os.Getenv(env); locale != "" {
+				break
+			}
+		}
+	}
+	if locale == "" || locale == "C" || locale == "POSIX" {
+		locale = defaultComposeLocale
+	}
+	return &composeLoader{
+		table:  &ComposeTable{root: &composeNode{}},
+		locale: locale,
+	}
+}
+
+// localeFile returns the path of the system Compose file for the locale,
+// trying the locale with its codeset spelt as in compose.dir (UTF-8 rather
+// than utf8) if it is not listed as is
+func (l *composeLoader) localeFile() (string, error) {
+	dirFile := filepath.Join(DefaultComposeDir, "compose.dir")
+	f, err := os.Open(dirFile)
+	if err != nil {
+		return "", fmt.Errorf("could not open compose.dir: %w", err)
+	}
+	defer f.Close()
+	want := []string{l.locale}
+	if name, codeset, ok := strings.Cut(l.locale, "."); ok {
+		if c := strings.ToLower(strings.ReplaceAll(codeset, "-", "")); c == "utf8" {
+			want = append(want, name+".UTF-8")
+		}
+	}
+	files := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		// Older compose.dir files put a colon after the file name.
+		file := strings.TrimSuffix(fields[0], ":")
+		if _, ok := files[fields[1]]; !ok {
+			files[fields[1]] = file
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("could not read compose.dir: %w", err)
+	}
+	for _, locale := range want {
+		if file, ok := files[locale]; ok {
+			return filepath.Join(DefaultComposeDir, file), nil
+		}
+	}
+	return "", fmt.Errorf("no Compose file for locale %s", l.locale)
+}
+
+func (l *composeLoader) load(path string, depth int) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("could not open Compose file: %w", err)
+	}
+	defer f.Close()
+	return l.parse(f, path, depth)
+}
+
+func (l *composeLoader) parse(r io.Reader, path string, depth int) error {
+	if depth > composeMaxIncludeDepth {
+		return errors.New("includes nested too deeply")
+	}
+	name := path
+	if name == "" {
+		name = "compose"
+	}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		if err := l.parseLine(scanner.Text(), path, depth); err != nil {
+			return fmt.Errorf("%s:%d: %w", name, line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("could not read %s: %w", name, err)
+	}
+	return nil
+}
+
+// parseLine will parse a single line of a Compose file, which is either an
+// include or a sequence of keysyms followed by the text they produce:
+//
+//	include "%L"
+//	<dead_acute> <e> : "é" eacute
+//
+// Sequences that need modifiers or use keysyms with no character are
+// skipped.
+func (l *composeLoader) parseLine(line, path string, depth int) error {
+	line = strings.TrimSpace(line)
+	if line == "" || line[0] == '#' {
+		return nil
+	}
+	if rest, ok := strings.CutPrefix(line, "include"); ok {
+		file, _, err := parseComposeString(strings.TrimSpace(rest))
+		if err != nil {
+			return fmt.Errorf("bad include: %w", err)
+		}
+		return l.include(file, path, depth)
+	}
+	lhs, rhs, ok := strings.Cut(line, ":")
+	if !ok {
+		return errors.New("missing ':'")
+	}
+	var seq []composeSym
+	skip := false
+	for _, field := range strings.Fields(lhs) {
+		if len(field) < 3 || field[0] != '<' || field[len(field)-1] != '>' {
+			// Modifiers, such as "!Ctrl <a>", are not supported.
+			skip = true
+			continue
+		}
+		s, ok := parseComposeSym(field[1 : len(field)-1])
+		if !ok {
+			skip = true
+		}
+		seq = append(seq, s)
+	}
+	if len(seq) == 0 {
+		return errors.New("no keysyms before ':'")
+	}
+	text, rest, err := l.parseResult(strings.TrimSpace(rhs))
+	if err != nil {
+		return err
+	}
+	if rest != "" && rest[0] != '#' {
+		return fmt.Errorf("unexpected %q", rest)
+	}
+	if skip || text == "" {
+		return nil
+	}
+	l.table.add(seq, text)
+	return nil
+}
+
+// parseResult parses the text a sequence produces, either a string, a
+// keysym, or a string followed by the keysym it is equivalent to. It returns
+// what follows on the line.
+func (l *composeLoader) parseResult(rhs string) (string, string, error) {
+	var text string
+	if strings.HasPrefix(rhs, `"`) {
+		s, rest, err := parseComposeString(rhs)
+		if err != nil {
+			return "", "", err
+		}
+		text, rhs = s, strings.TrimSpace(rest)
+	}
+	if rhs == "" || rhs[0] == '#' {
+		if text == "" {
+			return "", "", errors.New("no result after ':'")
+		}
+		return text, rhs, nil
+	}
+	sym := strings.Fields(rhs)[0]
+	rest := strings.TrimSpace(rhs[len(sym):])
+	if text == "" {
+		if r := keysymRune(sym); r != 0 {
+			text = string(r)
+		}
+	}
+	return text, rest, nil
+}
+
+// include will load an included file, expanding %L to the locale's Compose
+// file, %H to the home directory and %S to DefaultComposeDir
+func (l *composeLoader) include(file, path string, depth int) error {
+	var b strings.Builder
+	for i := 0; i < len(file); i++ {
+		if file[i] != '%' || i+1 == len(file) {
+			b.WriteByte(file[i])
+			continue
+		}
+		i++
+		switch file[i] {
+		case 'L':
+			p, err := l.localeFile()
+			if err != nil {
+				return err
+			}
+			b.WriteString(p)
+		case 'H':
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return err
+			}
+			b.WriteString(home)
+		case 'S':
+			b.WriteString(DefaultComposeDir)
+		case '%':
+			b.WriteByte('%')
+		default:
+			return fmt.Errorf("unknown substitution %%%c in include", file[i])
+		}
+	}
+	included := b.String()
+	if !filepath.IsAbs(included) && path != "" {
+		included = filepath.Join(filepath.Dir(path), included)
+	}
+	return l.load(included, depth+1)
+}
+
+// parseComposeString parses a double quoted string at the start of s,
+// returning it and the rest of s. As well as \" and \\, octal (\123) and hex
+// (\x53) escapes are allowed.
+func parseComposeString(s string) (string, string, error) {
+	if !strings.HasPrefix(s, `"`) {
+		return "", "", errors.New("expected a string")
+	}
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"':
+			return b.String(), s[i+1:], nil
+		case c != '\\':
+			b.WriteByte(c)
+			continue
+		case i+1 == len(s):
+			return "", "", errors.New("unterminated string")
+		}
+		i++
+		switch c = s[i]; {
+		case c == 'x' || c == 'X':
+			j := i + 1
+			for j < len(s) && j < i+3 && isHexDigit(s[j]) {
+				j++
+			}
+			v, err := strconv.ParseUint(s[i+1:j], 16, 8)
+			if err != nil {
+				return "", "", fmt.Errorf("bad escape %q", s[i-1:j])
+			}
+			b.WriteByte(byte(v))
+			i = j - 1
+		case c >= '0' && c <= '7':
+			j := i
+			for j < len(s) && j < i+3 && s[j] >= '0' && s[j] <= '7' {
+				j++
+			}
+			v, err := strconv.ParseUint(s[i:j], 8, 8)
+			if err != nil {
+				return "", "", fmt.Errorf("bad escape %q", s[i-1:j])
+			}
+			b.WriteByte(byte(v))
+			i = j - 1
+		case c == 'n':
+			b.WriteByte('\n')
+		case c == 'r':
+			b.WriteByte('\r')
+		case c == 't':
+			b.WriteByte('\t')
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", "", errors.New("unterminated string")
+}
+
+func isHexDigit(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+// composer tracks the compose sequence in progress on a keyboard being
+// snooped. A composer with no table does nothing.
+type composer struct {
+	table *ComposeTable
+	node  *composeNode
+}
+
+// compose will update a key event with the result of the compose sequence it
+// is part of. Key presses that start or continue a sequence produce no rune
+// (AsRune is 0), and the press that completes one has the text produced in
+// Composed and, if it is a single character, in AsRune. A key that does not
+// continue the sequence cancels it and, as with libX11, produces no rune
+// either. Modifiers and other keys that produce no character are passed
+// through untouched.
+func (c *composer) compose(e *KeyEvent) {
+	if c.table == nil || e.eventRaw.Type != evKey || e.Value == 0 {
+		return
+	}
+	if e.Value == 2 {
+		if c.node != nil {
+			e.AsRune = 0
+		}
+		return
+	}
+	sym, ok := c.sym(e)
+	if !ok {
+		return
+	}
+	n := c.table.root
+	if c.node != nil {
+		n = c.node
+	}
+	next := n.next[sym]
+	switch {
+	case next == nil && c.node == nil:
+		return
+	case next == nil:
+		c.node = nil
+		e.AsRune = 0
+	case len(next.next) > 0:
+		c.node = next
+		e.AsRune = 0
+	default:
+		c.node = nil
+		e.Composed = next.text
+		e.AsRune = 0
+		if utf8.RuneCountInString(next.text) == 1 {
+			e.AsRune, _ = utf8.DecodeRuneInString(next.text)
+		}
+	}
+}
+
+// sym returns the compose step for a key press, or false if the key is not
+// part of any sequence
+func (c *composer) sym(e *KeyEvent) (composeSym, bool) {
+	layout := DefaultLayout()
+	if e.Device != nil {
+		layout = e.Device.Layout()
+	}
+	code := int(e.eventRaw.Code)
+	switch name := layout.DeadKey(code, e.Modifiers.Level()); {
+	case name != "":
+		return composeSym{name: name}, true
+	case code == keyCompose:
+		return composeSym{name: "Multi_key"}, true
+	case e.AsRune != 0:
+		return composeSym{r: e.AsRune}, true
+	}
+	return composeSym{}, false
+}
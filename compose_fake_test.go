@@ -0,0 +1,41 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/joshuar/gokbd"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnooper_Compose(t *testing.T) {
+	table, err := gokbd.ParseCompose(strings.NewReader(`
+<dead_acute> <e>	: "é"	eacute
+<Multi_key> <o> <c>	: "©"	copyright
+`))
+	assert.Nil(t, err)
+	qwertz, err := gokbd.LayoutByName("de")
+	assert.Nil(t, err)
+	fake := newKeyboard(t)
+	kbd := gokbd.NewKeyboardDevice(fake)
+	kbd.SetLayout(qwertz)
+	keys := snoop(t, kbd, gokbd.WithCompose(table)).Keys()
+	for _, k := range []gokbd.Key{gokbd.KeyEqual, gokbd.KeyE, gokbd.KeyCompose, gokbd.KeyO, gokbd.KeyC, gokbd.KeyA} {
+		fake.Tap(int(k))
+	}
+
+	var runes []rune
+	var composed []string
+	for i := 0; i < 6; i++ {
+		ev := nextKeyPress(t, keys)
+		runes = append(runes, ev.AsRune)
+		composed = append(composed, ev.Composed)
+	}
+	assert.Equal(t, []rune{0, 'é', 0, 0, '©', 'a'}, runes)
+	assert.Equal(t, []string{"", "é", "", "", "©", ""}, composed)
+}
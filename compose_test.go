@@ -0,0 +1,195 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadComposeTable(t *testing.T) {
+	table, err := LoadComposeTable(filepath.Join("testdata", "compose", "Compose"))
+	assert.Nil(t, err)
+	tests := []struct {
+		name   string
+		syms   []string
+		want   string
+		wantOk bool
+	}{
+		{name: "dead key", syms: []string{"dead_acute", "e"}, want: "é", wantOk: true},
+		{name: "compose key", syms: []string{"Multi_key", "o", "c"}, want: "©", wantOk: true},
+		{name: "keysym result", syms: []string{"Multi_key", "x"}, want: "×", wantOk: true},
+		{name: "escapes", syms: []string{"Multi_key", "quotedbl", "backslash"}, want: `"\`, wantOk: true},
+		{name: "octal escapes", syms: []string{"Multi_key", "o", "e"}, want: "œ", wantOk: true},
+		{name: "hex escapes", syms: []string{"Multi_key", "e", "e"}, want: "ə", wantOk: true},
+		{name: "included", syms: []string{"dead_acute", "a"}, want: "á", wantOk: true},
+		{name: "dead keys", syms: []string{"dead_circumflex", "dead_circumflex"}, want: "^", wantOk: true},
+		{name: "long sequence", syms: []string{"Multi_key", "minus", "minus", "period"}, want: "–", wantOk: true},
+		{name: "prefix replaced by longer sequence", syms: []string{"Multi_key", "o"}},
+		{name: "incomplete", syms: []string{"Multi_key", "minus", "minus"}},
+		{name: "unknown", syms: []string{"dead_acute", "x"}},
+		{name: "modifiers skipped", syms: []string{"a"}},
+		{name: "no character skipped", syms: []string{"dead_acute", "KP_Divide"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := table.lookup(tt.syms...)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLoadLocaleComposeTable(t *testing.T) {
+	if _, err := os.Stat(filepath.Join(DefaultComposeDir, "compose.dir")); err != nil {
+		t.Skip("no X11 locale data installed")
+	}
+	for _, locale := range []string{"en_US.UTF-8", "en_US.utf8"} {
+		table, err := LoadLocaleComposeTable(locale)
+		if !assert.Nil(t, err, locale) {
+			continue
+		}
+		got, ok := table.lookup("dead_acute", "e")
+		assert.True(t, ok, locale)
+		assert.Equal(t, "é", got, locale)
+		got, ok = table.lookup("Multi_key", "o", "c")
+		assert.True(t, ok, locale)
+		assert.Equal(t, "©", got, locale)
+	}
+	_, err := LoadLocaleComposeTable("xx_XX.NOTHING")
+	assert.NotNil(t, err)
+}
+
+func TestParseCompose(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		wantErr bool
+	}{
+		{name: "comments and blank lines", src: "# comment\n\n   \n"},
+		{name: "sequence", src: `<dead_grave> <a> : "à" agrave`},
+		{name: "missing colon", src: `<dead_grave> <a> "à"`, wantErr: true},
+		{name: "no keysyms", src: `: "à"`, wantErr: true},
+		{name: "no result", src: `<dead_grave> <a> :`, wantErr: true},
+		{name: "unterminated string", src: `<dead_grave> <a> : "à`, wantErr: true},
+		{name: "trailing text", src: `<dead_grave> <a> : "à" agrave oops`, wantErr: true},
+		{name: "bad escape", src: `<dead_grave> <a> : "\xZZ"`, wantErr: true},
+		{name: "missing include", src: `include "testdata/compose/nothere"`, wantErr: true},
+		{name: "bad substitution", src: `include "%Q"`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseCompose(strings.NewReader(tt.src))
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func Test_composer_compose(t *testing.T) {
+	const (
+		keyE     = 18
+		keyO     = 24
+		keyA     = 30
+		keyC     = 46
+		keyEqual = 13
+	)
+	table, err := LoadComposeTable(filepath.Join("testdata", "compose", "Compose"))
+	assert.Nil(t, err)
+	qwertz, err := LayoutByName("de")
+	assert.Nil(t, err)
+	kbd := &KeyboardDevice{}
+	kbd.SetLayout(qwertz)
+	type key struct {
+		code  int
+		value int
+	}
+	press := func(codes ...int) []key {
+		var keys []key
+		for _, code := range codes {
+			keys = append(keys, key{code: code, value: 1}, key{code: code})
+		}
+		return keys
+	}
+	tests := []struct {
+		name         string
+		table        *ComposeTable
+		keys         []key
+		wantRunes    []rune
+		wantComposed []string
+	}{
+		{
+			name:         "dead key",
+			table:        table,
+			keys:         press(keyEqual, keyE),
+			wantRunes:    []rune{0, 'é'},
+			wantComposed: []string{"", "é"},
+		},
+		{
+			name:         "compose key",
+			table:        table,
+			keys:         press(keyCompose, keyO, keyC, keyA),
+			wantRunes:    []rune{0, 0, '©', 'a'},
+			wantComposed: []string{"", "", "©", ""},
+		},
+		{
+			name:         "multiple characters",
+			table:        table,
+			keys:         press(keyCompose, keyO, keyE),
+			wantRunes:    []rune{0, 0, 'œ'},
+			wantComposed: []string{"", "", "œ"},
+		},
+		{
+			name:         "cancelled",
+			table:        table,
+			keys:         press(keyEqual, keyC, keyA),
+			wantRunes:    []rune{0, 0, 'a'},
+			wantComposed: []string{"", "", ""},
+		},
+		{
+			name:         "repeat while composing",
+			table:        table,
+			keys:         []key{{code: keyEqual, value: 1}, {code: keyEqual, value: 2}, {code: keyEqual}, {code: keyE, value: 1}},
+			wantRunes:    []rune{0, 'é'},
+			wantComposed: []string{"", "é"},
+		},
+		{
+			name:         "no table",
+			keys:         press(keyEqual, keyE),
+			wantRunes:    []rune{0, 'e'},
+			wantComposed: []string{"", ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &composer{table: tt.table}
+			var runes []rune
+			var composed []string
+			for _, k := range tt.keys {
+				e := NewKeyEvent(InputEvent{Type: evKey, Code: uint16(k.code), Value: int32(k.value)})
+				e.Device = kbd
+				e.updateRune(qwertz, &e.Modifiers)
+				c.compose(e)
+				if k.value == 2 {
+					assert.Zero(t, e.AsRune)
+				}
+				if k.value == 1 {
+					runes = append(runes, e.AsRune)
+					composed = append(composed, e.Composed)
+				}
+			}
+			assert.Equal(t, tt.wantRunes, runes)
+			assert.Equal(t, tt.wantComposed, composed)
+		})
+	}
+}
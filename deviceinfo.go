@@ -0,0 +1,112 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+const sysClassInputPath = "/sys/class/input"
+
+// BusType represents the bus through which a device is connected, see
+// the BUS_* definitions in linux/input.h
+type BusType uint16
+
+const (
+	BusPCI        BusType = 0x01
+	BusISAPnP     BusType = 0x02
+	BusUSB        BusType = 0x03
+	BusHIL        BusType = 0x04
+	BusBluetooth  BusType = 0x05
+	BusVirtual    BusType = 0x06
+	BusISA        BusType = 0x10
+	BusI8042      BusType = 0x11
+	BusXTKBD      BusType = 0x12
+	BusRS232      BusType = 0x13
+	BusGameport   BusType = 0x14
+	BusParport    BusType = 0x15
+	BusAmiga      BusType = 0x16
+	BusADB        BusType = 0x17
+	BusI2C        BusType = 0x18
+	BusHost       BusType = 0x19
+	BusGSC        BusType = 0x1A
+	BusAtari      BusType = 0x1B
+	BusSPI        BusType = 0x1C
+	BusRMI        BusType = 0x1D
+	BusCEC        BusType = 0x1E
+	BusIntelISHTP BusType = 0x1F
+)
+
+var busNames = map[BusType]string{
+	BusPCI:        "pci",
+	BusISAPnP:     "isapnp",
+	BusUSB:        "usb",
+	BusHIL:        "hil",
+	BusBluetooth:  "bluetooth",
+	BusVirtual:    "virtual",
+	BusISA:        "isa",
+	BusI8042:      "i8042",
+	BusXTKBD:      "xtkbd",
+	BusRS232:      "rs232",
+	BusGameport:   "gameport",
+	BusParport:    "parport",
+	BusAmiga:      "amiga",
+	BusADB:        "adb",
+	BusI2C:        "i2c",
+	BusHost:       "host",
+	BusGSC:        "gsc",
+	BusAtari:      "atari",
+	BusSPI:        "spi",
+	BusRMI:        "rmi",
+	BusCEC:        "cec",
+	BusIntelISHTP: "intel-ishtp",
+}
+
+func (b BusType) String() string {
+	if name, ok := busNames[b]; ok {
+		return name
+	}
+	return fmt.Sprintf("bus(%#02x)", uint16(b))
+}
+
+// DeviceInfo contains the identifying details of an input device as reported
+// by the kernel. Name, Phys and Uniq are the device name, physical location
+// and unique identifier (often a serial number), any of which may be empty.
+// DevNode is the path of the device node (for example /dev/input/event3) and
+// SysPath is the path of the device in sysfs.
+type DeviceInfo struct {
+	Name          string
+	Phys          string
+	Uniq          string
+	DevNode       string
+	SysPath       string
+	DriverVersion int
+	BusType       BusType
+	Vendor        uint16
+	Product       uint16
+	Version       uint16
+}
+
+// String will format the device details in a human-friendly way, for example
+// "Logitech K120 (046d:c31c) on usb-0000:00:14.0-2"
+func (i DeviceInfo) String() string {
+	s := fmt.Sprintf("%s (%04x:%04x)", i.Name, i.Vendor, i.Product)
+	if i.Phys != "" {
+		s += " on " + i.Phys
+	}
+	return s
+}
+
+// sysPathForDevNode will find the sysfs path of the input device that owns
+// the given event device node, returning an empty string if it cannot be found
+func sysPathForDevNode(devNode string) string {
+	path, err := filepath.EvalSymlinks(filepath.Join(sysClassInputPath, filepath.Base(devNode), "device"))
+	if err != nil {
+		return ""
+	}
+	return path
+}
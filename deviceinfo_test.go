@@ -0,0 +1,79 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd
+
+import "testing"
+
+func TestBusType_String(t *testing.T) {
+	tests := []struct {
+		name string
+		b    BusType
+		want string
+	}{
+		{
+			name: "known bus",
+			b:    BusUSB,
+			want: "usb",
+		},
+		{
+			name: "unknown bus",
+			b:    BusType(0x42),
+			want: "bus(0x42)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.b.String(); got != tt.want {
+				t.Errorf("BusType.String() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeviceInfo_String(t *testing.T) {
+	type fields struct {
+		Name    string
+		Phys    string
+		Vendor  uint16
+		Product uint16
+	}
+	tests := []struct {
+		name   string
+		fields fields
+		want   string
+	}{
+		{
+			name: "with phys",
+			fields: fields{
+				Name:    "Logitech K120",
+				Phys:    "usb-0000:00:14.0-2",
+				Vendor:  0x046d,
+				Product: 0xc31c,
+			},
+			want: "Logitech K120 (046d:c31c) on usb-0000:00:14.0-2",
+		},
+		{
+			name: "without phys",
+			fields: fields{
+				Name: "gokbd virtual keyboard",
+			},
+			want: "gokbd virtual keyboard (0000:0000)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := DeviceInfo{
+				Name:    tt.fields.Name,
+				Phys:    tt.fields.Phys,
+				Vendor:  tt.fields.Vendor,
+				Product: tt.fields.Product,
+			}
+			if got := i.String(); got != tt.want {
+				t.Errorf("DeviceInfo.String() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/joshuar/gokbd"
+	"github.com/joshuar/gokbd/gokbdtest"
+)
+
+// * The tests in press_test.go and the *_fake_test.go files use the fake
+// * keyboards and sinks of the gokbdtest package, set up with these helpers.
+
+// newVirtualKeyboard returns a virtual keyboard writing to a new sink. It is
+// closed once the test has finished.
+func newVirtualKeyboard(t *testing.T, opts ...gokbd.VirtualKeyboardOption) (*gokbd.VirtualKeyboardDevice, *gokbdtest.Sink) {
+	t.Helper()
+	sink, err := gokbdtest.NewSink()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newVirtualKeyboardWithSink(t, sink, opts...), sink
+}
+
+// newVirtualKeyboardWithSink returns a virtual keyboard writing to the sink.
+// It is closed once the test has finished.
+func newVirtualKeyboardWithSink(t *testing.T, sink gokbd.EventSink, opts ...gokbd.VirtualKeyboardOption) *gokbd.VirtualKeyboardDevice {
+	t.Helper()
+	v, err := gokbd.NewVirtualKeyboardWithSink("fake", sink, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(v.Close)
+	return v
+}
+
+// newKeyboard returns a fake keyboard that supports every key
+func newKeyboard(t *testing.T) *gokbdtest.Keyboard {
+	t.Helper()
+	fake, err := gokbdtest.NewKeyboard(gokbd.DeviceInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fake
+}
+
+// snoop returns a snooper reading the keyboard. It is stopped once the test
+// has finished.
+func snoop(t *testing.T, kbd *gokbd.KeyboardDevice, opts ...gokbd.SnooperOption) *gokbd.Snooper {
+	t.Helper()
+	kbds := make(chan *gokbd.KeyboardDevice, 1)
+	kbds <- kbd
+	close(kbds)
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	t.Cleanup(cancelFunc)
+	s, err := gokbd.NewSnooper(ctx, kbds, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// nextKeyPress returns the next key press snooped, skipping any other events
+func nextKeyPress(t *testing.T, keys <-chan gokbd.KeyEvent) gokbd.KeyEvent {
+	t.Helper()
+	for {
+		select {
+		case ev, ok := <-keys:
+			if !ok {
+				t.Fatal("key channel closed")
+			}
+			if ev.IsKeyPress() {
+				return ev
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for key press")
+		}
+	}
+}
+
+func keyEvent(k gokbd.Key, value int32) gokbd.InputEvent {
+	return gokbd.InputEvent{Type: uint16(gokbd.EventKey), Code: uint16(k), Value: value}
+}
+
+func syncEvent() gokbd.InputEvent {
+	return gokbd.InputEvent{Type: uint16(gokbd.EventSyn)}
+}
+
+// pressed returns the key codes of the keys, as Sink.Pressed does
+func pressed(keys ...gokbd.Key) []int {
+	codes := make([]int, 0, len(keys))
+	for _, k := range keys {
+		codes = append(codes, int(k))
+	}
+	return codes
+}
+
+// keyEvents returns the events written to the sink without their times
+func keyEvents(sink *gokbdtest.Sink) []gokbd.InputEvent {
+	var events []gokbd.InputEvent
+	for _, ev := range sink.Events() {
+		events = append(events, gokbd.InputEvent{Type: ev.Type, Code: ev.Code, Value: ev.Value})
+	}
+	return events
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd
+
+import (
+	"regexp"
+	"sync"
+)
+
+// ownVirtualDevices tracks the device nodes of any virtual keyboards created
+// by this process, so they can be excluded when opening keyboards
+var ownVirtualDevices sync.Map
+
+func registerVirtualDevice(devNode string) {
+	ownVirtualDevices.Store(devNode, struct{}{})
+}
+
+func unregisterVirtualDevice(devNode string) {
+	ownVirtualDevices.Delete(devNode)
+}
+
+func isOwnVirtualDevice(devNode string) bool {
+	_, ok := ownVirtualDevices.Load(devNode)
+	return ok
+}
+
+// DeviceFilter describes which keyboards should be selected when opening or
+// snooping keyboards. Every field that is set must match for a keyboard to be
+// selected, unset (zero-valued) fields match anything:
+//
+//   - Name and Phys are matched against the device name and physical location.
+//   - Vendor and Product are matched against the device IDs.
+//   - BusTypes lists the acceptable buses the device can be connected through.
+//   - RequiredKeys lists the keys the device must support.
+//   - ExcludeOwnVirtual skips virtual keyboards created with
+//     NewVirtualKeyboard by this process.
+type DeviceFilter struct {
+	Name              *regexp.Regexp
+	Phys              *regexp.Regexp
+	BusTypes          []BusType
+	RequiredKeys      []Key
+	Vendor            uint16
+	Product           uint16
+	ExcludeOwnVirtual bool
+}
+
+// Match will return true when the given keyboard matches the filter
+func (f DeviceFilter) Match(kbd *KeyboardDevice) bool {
+	if f.Name != nil && !f.Name.MatchString(kbd.Name) {
+		return false
+	}
+	if f.Phys != nil && !f.Phys.MatchString(kbd.Phys) {
+		return false
+	}
+	if f.Vendor != 0 && f.Vendor != kbd.Vendor {
+		return false
+	}
+	if f.Product != 0 && f.Product != kbd.Product {
+		return false
+	}
+	if len(f.BusTypes) > 0 {
+		found := false
+		for _, b := range f.BusTypes {
+			if b == kbd.BusType {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.ExcludeOwnVirtual && isOwnVirtualDevice(kbd.DevNode) {
+		return false
+	}
+	for _, k := range f.RequiredKeys {
+		if !kbd.hasKey(int(k)) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchFilters will return true when the given keyboard matches any of the
+// filters or no filters were given
+func matchFilters(kbd *KeyboardDevice, filters []DeviceFilter) bool {
+	if len(filters) == 0 {
+		return true
+	}
+	for _, f := range filters {
+		if f.Match(kbd) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,22 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd_test
+
+import (
+	"testing"
+
+	"github.com/joshuar/gokbd"
+	"github.com/joshuar/gokbd/gokbdtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeviceFilter_RequiredKeys(t *testing.T) {
+	fake, err := gokbdtest.NewKeyboard(gokbd.DeviceInfo{}, int(gokbd.KeyCapsLock), int(gokbd.KeyA))
+	assert.Nil(t, err)
+	kbd := gokbd.NewKeyboardDevice(fake)
+	assert.True(t, gokbd.DeviceFilter{RequiredKeys: []gokbd.Key{gokbd.KeyA}}.Match(kbd))
+	assert.False(t, gokbd.DeviceFilter{RequiredKeys: []gokbd.Key{gokbd.KeyA, gokbd.KeyF5}}.Match(kbd))
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestDeviceFilter_Match(t *testing.T) {
+	registerVirtualDevice("/dev/input/event99")
+	defer unregisterVirtualDevice("/dev/input/event99")
+	realKbd := &KeyboardDevice{
+		DeviceInfo: DeviceInfo{
+			Name:    "Logitech K120",
+			Phys:    "usb-0000:00:14.0-2/input0",
+			DevNode: "/dev/input/event3",
+			BusType: BusUSB,
+			Vendor:  0x046d,
+			Product: 0xc31c,
+		},
+	}
+	virtualKbd := &KeyboardDevice{
+		DeviceInfo: DeviceInfo{
+			Name:    "gokbd virtual keyboard",
+			DevNode: "/dev/input/event99",
+			BusType: BusVirtual,
+		},
+	}
+	tests := []struct {
+		name   string
+		filter DeviceFilter
+		kbd    *KeyboardDevice
+		want   bool
+	}{
+		{
+			name:   "empty filter",
+			filter: DeviceFilter{},
+			kbd:    realKbd,
+			want:   true,
+		},
+		{
+			name:   "name match",
+			filter: DeviceFilter{Name: regexp.MustCompile(`(?i)logitech`)},
+			kbd:    realKbd,
+			want:   true,
+		},
+		{
+			name:   "name mismatch",
+			filter: DeviceFilter{Name: regexp.MustCompile(`YubiKey`)},
+			kbd:    realKbd,
+			want:   false,
+		},
+		{
+			name:   "phys match",
+			filter: DeviceFilter{Phys: regexp.MustCompile(`^usb-`)},
+			kbd:    realKbd,
+			want:   true,
+		},
+		{
+			name:   "vendor and product match",
+			filter: DeviceFilter{Vendor: 0x046d, Product: 0xc31c},
+			kbd:    realKbd,
+			want:   true,
+		},
+		{
+			name:   "product mismatch",
+			filter: DeviceFilter{Vendor: 0x046d, Product: 0xc52b},
+			kbd:    realKbd,
+			want:   false,
+		},
+		{
+			name:   "bus type match",
+			filter: DeviceFilter{BusTypes: []BusType{BusBluetooth, BusUSB}},
+			kbd:    realKbd,
+			want:   true,
+		},
+		{
+			name:   "bus type mismatch",
+			filter: DeviceFilter{BusTypes: []BusType{BusUSB}},
+			kbd:    virtualKbd,
+			want:   false,
+		},
+		{
+			name:   "exclude own virtual",
+			filter: DeviceFilter{ExcludeOwnVirtual: true},
+			kbd:    virtualKbd,
+			want:   false,
+		},
+		{
+			name:   "exclude own virtual (real keyboard)",
+			filter: DeviceFilter{ExcludeOwnVirtual: true},
+			kbd:    realKbd,
+			want:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(tt.kbd); got != tt.want {
+				t.Errorf("DeviceFilter.Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_matchFilters(t *testing.T) {
+	kbd := &KeyboardDevice{
+		DeviceInfo: DeviceInfo{Name: "Logitech K120", BusType: BusUSB},
+	}
+	tests := []struct {
+		name    string
+		filters []DeviceFilter
+		want    bool
+	}{
+		{
+			name:    "no filters",
+			filters: nil,
+			want:    true,
+		},
+		{
+			name: "one of many matches",
+			filters: []DeviceFilter{
+				{BusTypes: []BusType{BusBluetooth}},
+				{Name: regexp.MustCompile(`K120`)},
+			},
+			want: true,
+		},
+		{
+			name: "none match",
+			filters: []DeviceFilter{
+				{BusTypes: []BusType{BusBluetooth}},
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchFilters(kbd, tt.filters); got != tt.want {
+				t.Errorf("matchFilters() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
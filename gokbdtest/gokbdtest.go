@@ -0,0 +1,469 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package gokbdtest provides in-memory fakes of the gokbd EventSource and
+// EventSink interfaces, so code that snoops or types keys can be tested without
+// real keyboards or access to /dev/input and /dev/uinput.
+//
+// A Keyboard is fed scripted events and passed to gokbd.NewKeyboardDevice,
+// after which it can be used with gokbd.SnoopKeyboard like any other keyboard.
+// A Sink records the events written by a gokbd.VirtualKeyboardDevice created
+// with gokbd.NewVirtualKeyboardWithSink.
+package gokbdtest
+
+import (
+	"sort"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/joshuar/gokbd"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	evSyn     = 0x00
+	evKey     = 0x01
+	evLed     = 0x11
+	synReport = 0
+
+	defaultRepeatDelay  = 250
+	defaultRepeatPeriod = 33
+)
+
+// Keyboard is a fake keyboard implementing gokbd.EventSource. Events pushed to
+// it are returned, in order, by NextEvent.
+type Keyboard struct {
+	info    gokbd.DeviceInfo
+	keys    map[int]bool
+	down    map[int]bool
+	leds    map[int]bool
+	repeat  [2]int
+	queue   []gokbd.InputEvent
+	mu      sync.Mutex
+	efd     int
+	grabbed bool
+	removed bool
+	closed  bool
+}
+
+// NewKeyboard will create a fake keyboard with the given details that reports
+// supporting the given key codes. If no key codes are given, the keyboard
+// supports every key.
+func NewKeyboard(info gokbd.DeviceInfo, keys ...int) (*Keyboard, error) {
+	efd, err := unix.Eventfd(0, unix.EFD_NONBLOCK|unix.EFD_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	k := &Keyboard{
+		info:   info,
+		down:   make(map[int]bool),
+		leds:   make(map[int]bool),
+		repeat: [2]int{defaultRepeatDelay, defaultRepeatPeriod},
+		efd:    efd,
+	}
+	if len(keys) > 0 {
+		k.keys = make(map[int]bool)
+		for _, code := range keys {
+			k.keys[code] = true
+		}
+	}
+	return k, nil
+}
+
+// Push will queue the given events to be read from the keyboard. Events with
+// a zero Time are given the current time. Like a real keyboard, the key and
+// LED state reported by KeysDown and LEDsOn is updated as soon as events are
+// pushed.
+func (k *Keyboard) Push(events ...gokbd.InputEvent) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for _, ev := range events {
+		if ev.Time.IsZero() {
+			ev.Time = time.Now()
+		}
+		switch {
+		case ev.Type == evKey && ev.Value != 2:
+			k.down[int(ev.Code)] = ev.Value == 1
+		case ev.Type == evLed:
+			k.leds[int(ev.Code)] = ev.Value != 0
+		}
+		k.queue = append(k.queue, ev)
+	}
+	k.signal()
+}
+
+// Press will queue a key press of the given key code, followed by a SYN_REPORT
+func (k *Keyboard) Press(code int) {
+	k.Push(keyEvent(code, 1), syncEvent())
+}
+
+// Release will queue a key release of the given key code, followed by a
+// SYN_REPORT
+func (k *Keyboard) Release(code int) {
+	k.Push(keyEvent(code, 0), syncEvent())
+}
+
+// Tap will queue a press and then a release of the given key code
+func (k *Keyboard) Tap(code int) {
+	k.Push(keyEvent(code, 1), syncEvent(), keyEvent(code, 0), syncEvent())
+}
+
+// Hold will mark the given key codes as held down without queuing any events,
+// as if they were pressed before the keyboard was opened
+func (k *Keyboard) Hold(codes ...int) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for _, code := range codes {
+		k.down[code] = true
+	}
+}
+
+// Light will mark the given LED codes as lit without queuing any events, as
+// if they were turned on before the keyboard was opened
+func (k *Keyboard) Light(codes ...int) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for _, code := range codes {
+		k.leds[code] = true
+	}
+}
+
+// SetLED implements gokbd.EventSource. Like a real keyboard, an EV_LED event
+// (followed by a SYN_REPORT) is queued to report the change.
+func (k *Keyboard) SetLED(code int, on bool) error {
+	if k.Closed() {
+		return unix.EBADF
+	}
+	k.Push(ledEvent(code, on), syncEvent())
+	return nil
+}
+
+// Remove will simulate the keyboard being disconnected. Any events already
+// queued can still be read, after which NextEvent returns
+// gokbd.ErrDeviceRemoved.
+func (k *Keyboard) Remove() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.removed = true
+	k.signal()
+}
+
+// Grabbed will return true while the keyboard is grabbed
+func (k *Keyboard) Grabbed() bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.grabbed
+}
+
+// Closed will return true once the keyboard has been closed
+func (k *Keyboard) Closed() bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.closed
+}
+
+// Info implements gokbd.EventSource
+func (k *Keyboard) Info() gokbd.DeviceInfo {
+	return k.info
+}
+
+// HasKey implements gokbd.EventSource
+func (k *Keyboard) HasKey(code int) bool {
+	return k.keys == nil || k.keys[code]
+}
+
+// KeysDown implements gokbd.EventSource
+func (k *Keyboard) KeysDown() ([]int, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return setCodes(k.down), nil
+}
+
+// LEDsOn implements gokbd.EventSource
+func (k *Keyboard) LEDsOn() ([]int, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return setCodes(k.leds), nil
+}
+
+// Repeat implements gokbd.EventSource
+func (k *Keyboard) Repeat() (int, int, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.repeat[0], k.repeat[1], nil
+}
+
+// SetRepeat implements gokbd.EventSource
+func (k *Keyboard) SetRepeat(delay, period int) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.repeat = [2]int{delay, period}
+	return nil
+}
+
+// Grab implements gokbd.EventSource
+func (k *Keyboard) Grab(grab bool) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.removed {
+		return gokbd.ErrDeviceRemoved
+	}
+	k.grabbed = grab
+	return nil
+}
+
+// NextEvent implements gokbd.EventSource
+func (k *Keyboard) NextEvent() (gokbd.InputEvent, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if len(k.queue) == 0 {
+		if k.removed {
+			return gokbd.InputEvent{}, gokbd.ErrDeviceRemoved
+		}
+		return gokbd.InputEvent{}, gokbd.ErrNoEvents
+	}
+	ev := k.queue[0]
+	k.queue = k.queue[1:]
+	if len(k.queue) == 0 && !k.removed {
+		k.drain()
+	}
+	return ev, nil
+}
+
+// Fd implements gokbd.EventSource. The returned file descriptor is readable
+// whenever events are queued or the keyboard has been removed.
+func (k *Keyboard) Fd() int {
+	return k.efd
+}
+
+// Close implements gokbd.EventSource
+func (k *Keyboard) Close() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.closed {
+		return nil
+	}
+	k.closed = true
+	return unix.Close(k.efd)
+}
+
+// signal will make the eventfd readable, k.mu must be held
+func (k *Keyboard) signal() {
+	if !k.closed {
+		signalFd(k.efd)
+	}
+}
+
+// drain will reset the eventfd so it is no longer readable, k.mu must be held
+func (k *Keyboard) drain() {
+	drainFd(k.efd)
+}
+
+// signalFd will make the given eventfd readable
+func signalFd(efd int) {
+	one := uint64(1)
+	_, _ = unix.Write(efd, (*[8]byte)(unsafe.Pointer(&one))[:])
+}
+
+// drainFd will reset the given eventfd so it is no longer readable
+func drainFd(efd int) {
+	var buf [8]byte
+	_, _ = unix.Read(efd, buf[:])
+}
+
+// Sink is a fake output device implementing gokbd.EventBatchSink. It records
+// all events written to it and, if created with NewLoopback, forwards them to
+// a fake Keyboard.
+type Sink struct {
+	events   []gokbd.InputEvent
+	writes   int
+	back     []gokbd.InputEvent
+	loopback *Keyboard
+	repeat   [2]int
+	mu       sync.Mutex
+	efd      int
+	closed   bool
+}
+
+// NewSink will create a new Sink that records the events written to it
+func NewSink() (*Sink, error) {
+	efd, err := unix.Eventfd(0, unix.EFD_NONBLOCK|unix.EFD_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	return &Sink{
+		efd:    efd,
+		repeat: [2]int{defaultRepeatDelay, defaultRepeatPeriod},
+	}, nil
+}
+
+// NewLoopback will create a new Sink that records the events written to it
+// and also pushes them to the given keyboard, so anything typed on a virtual
+// keyboard can be snooped back.
+func NewLoopback(kbd *Keyboard) (*Sink, error) {
+	s, err := NewSink()
+	if err != nil {
+		return nil, err
+	}
+	s.loopback = kbd
+	return s, nil
+}
+
+// SetLED will simulate the kernel turning the given LED code on or off, as it
+// does when a lock key is pressed on any keyboard. The change is reported
+// through gokbd.VirtualKeyboardDevice.LEDChanges.
+func (s *Sink) SetLED(code int, on bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.back = append(s.back, ledEvent(code, on))
+	signalFd(s.efd)
+}
+
+// WriteEvent implements gokbd.EventSink
+func (s *Sink) WriteEvent(ev gokbd.InputEvent) error {
+	return s.WriteEvents(ev)
+}
+
+// WriteEvents implements gokbd.EventBatchSink. Like the kernel, all the
+// events are given the same time.
+func (s *Sink) WriteEvents(evs ...gokbd.InputEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return unix.EBADF
+	}
+	start := len(s.events)
+	s.events = append(s.events, evs...)
+	now := time.Now()
+	for i := start; i < len(s.events); i++ {
+		s.events[i].Time = now
+	}
+	s.writes++
+	if s.loopback != nil {
+		s.loopback.Push(s.events[start:]...)
+	}
+	return nil
+}
+
+// Repeat implements gokbd.EventSink
+func (s *Sink) Repeat() (int, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.repeat[0], s.repeat[1], nil
+}
+
+// SetRepeat implements gokbd.EventSink
+func (s *Sink) SetRepeat(delay, period int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.repeat = [2]int{delay, period}
+	return nil
+}
+
+// NextEvent implements gokbd.EventSink
+func (s *Sink) NextEvent() (gokbd.InputEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.back) == 0 {
+		return gokbd.InputEvent{}, gokbd.ErrNoEvents
+	}
+	ev := s.back[0]
+	s.back = s.back[1:]
+	if len(s.back) == 0 {
+		drainFd(s.efd)
+	}
+	return ev, nil
+}
+
+// Fd implements gokbd.EventSink. The returned file descriptor is readable
+// whenever events set with SetLED are pending.
+func (s *Sink) Fd() int {
+	return s.efd
+}
+
+// Close implements gokbd.EventSink
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return unix.Close(s.efd)
+}
+
+// Events will return a copy of all events written so far
+func (s *Sink) Events() []gokbd.InputEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]gokbd.InputEvent(nil), s.events...)
+}
+
+// Pressed will return the key codes of all key presses written so far, in
+// order
+func (s *Sink) Pressed() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var codes []int
+	for _, ev := range s.events {
+		if ev.Type == evKey && ev.Value == 1 {
+			codes = append(codes, int(ev.Code))
+		}
+	}
+	return codes
+}
+
+// Writes will return how many writes (calls to WriteEvent or WriteEvents)
+// were made so far
+func (s *Sink) Writes() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writes
+}
+
+// Reset will forget all events written so far
+func (s *Sink) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = nil
+	s.writes = 0
+}
+
+// setCodes returns the sorted codes that are true in the given map
+func setCodes(m map[int]bool) []int {
+	var codes []int
+	for code, set := range m {
+		if set {
+			codes = append(codes, code)
+		}
+	}
+	sort.Ints(codes)
+	return codes
+}
+
+func keyEvent(code int, value int32) gokbd.InputEvent {
+	return gokbd.InputEvent{Type: evKey, Code: uint16(code), Value: value}
+}
+
+func ledEvent(code int, on bool) gokbd.InputEvent {
+	var value int32
+	if on {
+		value = 1
+	}
+	return gokbd.InputEvent{Type: evLed, Code: uint16(code), Value: value}
+}
+
+func syncEvent() gokbd.InputEvent {
+	return gokbd.InputEvent{Type: evSyn, Code: synReport}
+}
+
+var (
+	_ gokbd.EventSource = (*Keyboard)(nil)
+	_ gokbd.EventSink   = (*Sink)(nil)
+)
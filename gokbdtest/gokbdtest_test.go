@@ -0,0 +1,96 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbdtest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/joshuar/gokbd"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	keyA = 30
+	keyH = 35
+	keyI = 23
+)
+
+func nextKeyPress(t *testing.T, keys <-chan gokbd.KeyEvent) gokbd.KeyEvent {
+	t.Helper()
+	for {
+		select {
+		case ev, ok := <-keys:
+			if !ok {
+				t.Fatal("key channel closed")
+			}
+			if ev.IsKeyPress() {
+				return ev
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for key press")
+		}
+	}
+}
+
+func TestKeyboard_Snoop(t *testing.T) {
+	fake, err := NewKeyboard(gokbd.DeviceInfo{Name: "fake keyboard"})
+	assert.Nil(t, err)
+	kbd := gokbd.NewKeyboardDevice(fake)
+	assert.Equal(t, "fake keyboard", kbd.Name)
+
+	ctx, cancelFunc := context.WithCancel(context.TODO())
+	defer cancelFunc()
+	keys := gokbd.SnoopKeyboard(ctx, kbd)
+	fake.Tap(keyA)
+	ev := nextKeyPress(t, keys)
+	assert.Equal(t, "KEY_A", ev.EventName)
+	assert.Equal(t, 'a', ev.AsRune)
+	assert.Equal(t, kbd, ev.Device)
+}
+
+func TestKeyboard_Grab(t *testing.T) {
+	fake, err := NewKeyboard(gokbd.DeviceInfo{})
+	assert.Nil(t, err)
+	kbd := gokbd.NewKeyboardDevice(fake)
+	ungrab, err := kbd.Grab()
+	assert.Nil(t, err)
+	assert.True(t, fake.Grabbed())
+	assert.Nil(t, ungrab())
+	assert.False(t, fake.Grabbed())
+}
+
+func TestSink_TypeString(t *testing.T) {
+	sink, err := NewSink()
+	assert.Nil(t, err)
+	v, err := gokbd.NewVirtualKeyboardWithSink("fake", sink)
+	assert.Nil(t, err)
+	assert.Nil(t, v.TypeString("hi"))
+	assert.Equal(t, []int{keyH, keyI}, sink.Pressed())
+	sink.Reset()
+	assert.Empty(t, sink.Events())
+	v.Close()
+	assert.NotNil(t, v.TypeString("hi"))
+}
+
+func TestNewLoopback(t *testing.T) {
+	fake, err := NewKeyboard(gokbd.DeviceInfo{})
+	assert.Nil(t, err)
+	ctx, cancelFunc := context.WithCancel(context.TODO())
+	defer cancelFunc()
+	keys := gokbd.SnoopKeyboard(ctx, gokbd.NewKeyboardDevice(fake))
+	sink, err := NewLoopback(fake)
+	assert.Nil(t, err)
+	v, err := gokbd.NewVirtualKeyboardWithSink("fake", sink)
+	assert.Nil(t, err)
+	defer v.Close()
+	assert.Nil(t, v.TypeString("hI"))
+	assert.Equal(t, 'h', nextKeyPress(t, keys).AsRune)
+	// shift is pressed first to type the uppercase I
+	assert.Equal(t, "KEY_LEFTSHIFT", nextKeyPress(t, keys).EventName)
+	assert.Equal(t, 'I', nextKeyPress(t, keys).AsRune)
+}
@@ -0,0 +1,197 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// geneventcodes generates the event type and code name tables used by gokbd
+// from the kernel linux/input-event-codes.h header.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var defineRegexp = regexp.MustCompile(`^#define\s+([A-Z0-9_]+)\s+(0x[0-9a-fA-F]+|[0-9]+|[A-Z][A-Z0-9_]*)\b`)
+
+// codePrefixes maps the prefix of a code name to the event type it belongs to
+var codePrefixes = map[string]string{
+	"SYN_": "EV_SYN",
+	"KEY_": "EV_KEY",
+	"BTN_": "EV_KEY",
+	"REL_": "EV_REL",
+	"ABS_": "EV_ABS",
+	"MSC_": "EV_MSC",
+	"SW_":  "EV_SW",
+	"LED_": "EV_LED",
+	"SND_": "EV_SND",
+	"REP_": "EV_REP",
+}
+
+// skipNames are names that alias the first code of a group, the more specific
+// name is preferred. Key names are kept as aliases.
+var skipNames = map[string]bool{
+	"EV_VERSION":        true,
+	"BTN_MISC":          true,
+	"BTN_MOUSE":         true,
+	"BTN_JOYSTICK":      true,
+	"BTN_GAMEPAD":       true,
+	"BTN_DIGI":          true,
+	"BTN_WHEEL":         true,
+	"BTN_TRIGGER_HAPPY": true,
+}
+
+type define struct {
+	name  string
+	value uint16
+	// alias is set for names that are another name for a code
+	alias bool
+}
+
+func parse(path string) ([]define, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var defines []define
+	values := make(map[string]uint16)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		m := defineRegexp.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		name := m[1]
+		if strings.HasSuffix(name, "_MAX") || strings.HasSuffix(name, "_CNT") {
+			continue
+		}
+		d := define{name: name, alias: skipNames[name]}
+		if value, ok := values[m[2]]; ok {
+			// defined as another name, for example KEY_SCREENLOCK
+			d.value = value
+			d.alias = true
+		} else {
+			value, err := strconv.ParseUint(m[2], 0, 16)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse value of %s: %w", name, err)
+			}
+			d.value = uint16(value)
+		}
+		values[name] = d.value
+		defines = append(defines, d)
+	}
+	return defines, scanner.Err()
+}
+
+func generate(defines []define) ([]byte, error) {
+	types := make(map[string]uint16)
+	typeNames := make(map[uint16]string)
+	codeNames := make(map[string]map[uint16]string)
+	keyAliases := make(map[string]uint16)
+	for _, d := range defines {
+		if d.alias {
+			if strings.HasPrefix(d.name, "KEY_") || strings.HasPrefix(d.name, "BTN_") {
+				keyAliases[d.name] = d.value
+			}
+			continue
+		}
+		if strings.HasPrefix(d.name, "EV_") {
+			if _, ok := typeNames[d.value]; !ok {
+				typeNames[d.value] = d.name
+				types[d.name] = d.value
+			}
+			continue
+		}
+		for prefix, evType := range codePrefixes {
+			if !strings.HasPrefix(d.name, prefix) {
+				continue
+			}
+			if codeNames[evType] == nil {
+				codeNames[evType] = make(map[uint16]string)
+			}
+			if _, ok := codeNames[evType][d.value]; !ok {
+				codeNames[evType][d.value] = d.name
+			} else if evType == "EV_KEY" {
+				keyAliases[d.name] = d.value
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "// Code generated by geneventcodes from linux/input-event-codes.h. DO NOT EDIT.")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "package gokbd")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "var eventTypeNames = map[uint16]string{")
+	for _, v := range sortedKeys(typeNames) {
+		fmt.Fprintf(&buf, "%#02x: %q,\n", v, typeNames[v])
+	}
+	fmt.Fprintln(&buf, "}")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "var eventCodeNames = map[uint16]map[uint16]string{")
+	for _, t := range sortedKeys(typeNames) {
+		codes, ok := codeNames[typeNames[t]]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(&buf, "%#02x: { // %s\n", t, typeNames[t])
+		for _, c := range sortedKeys(codes) {
+			fmt.Fprintf(&buf, "%#03x: %q,\n", c, codes[c])
+		}
+		fmt.Fprintln(&buf, "},")
+	}
+	fmt.Fprintln(&buf, "}")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "// keyNameAliases are the other names of key codes, such as KEY_SCREENLOCK for")
+	fmt.Fprintln(&buf, "// KEY_COFFEE")
+	fmt.Fprintln(&buf, "var keyNameAliases = map[string]uint16{")
+	aliases := make([]string, 0, len(keyAliases))
+	for name := range keyAliases {
+		aliases = append(aliases, name)
+	}
+	sort.Strings(aliases)
+	for _, name := range aliases {
+		fmt.Fprintf(&buf, "%q: %#03x,\n", name, keyAliases[name])
+	}
+	fmt.Fprintln(&buf, "}")
+	return format.Source(buf.Bytes())
+}
+
+func sortedKeys(m map[uint16]string) []uint16 {
+	keys := make([]uint16, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+func main() {
+	out := flag.String("o", "eventcodes.go", "output file")
+	flag.Parse()
+	header := "/usr/include/linux/input-event-codes.h"
+	if flag.NArg() > 0 {
+		header = flag.Arg(0)
+	}
+	defines, err := parse(header)
+	if err != nil {
+		log.Fatal(err)
+	}
+	src, err := generate(defines)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
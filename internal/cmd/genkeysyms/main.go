@@ -0,0 +1,90 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// genkeysyms generates the table of X keysym names and the Unicode characters
+// they produce, used by gokbd to load XKB keymaps, from the X11/keysymdef.h
+// header.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// defineRegexp matches the keysyms with an exact Unicode equivalent. Those
+// with an approximate equivalent, noted as /*(U+xxxx ...)*/, are skipped.
+var defineRegexp = regexp.MustCompile(`^#define\s+XK_([a-zA-Z0-9_]+)\s+0x[0-9a-fA-F]+\s+/\*\s*U\+([0-9A-Fa-f]{4,6})\b`)
+
+type keysym struct {
+	name string
+	r    rune
+}
+
+func parse(path string) ([]keysym, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	seen := make(map[string]bool)
+	var keysyms []keysym
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		m := defineRegexp.FindStringSubmatch(scanner.Text())
+		if m == nil || seen[m[1]] {
+			continue
+		}
+		r, err := strconv.ParseUint(m[2], 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse value of %s: %w", m[1], err)
+		}
+		seen[m[1]] = true
+		keysyms = append(keysyms, keysym{name: m[1], r: rune(r)})
+	}
+	return keysyms, scanner.Err()
+}
+
+func generate(keysyms []keysym) ([]byte, error) {
+	sort.Slice(keysyms, func(i, j int) bool { return keysyms[i].name < keysyms[j].name })
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "// Code generated by genkeysyms from X11/keysymdef.h. DO NOT EDIT.")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "package gokbd")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "var keysymRunes = map[string]rune{")
+	for _, k := range keysyms {
+		fmt.Fprintf(&buf, "%q: %#04x,\n", k.name, k.r)
+	}
+	fmt.Fprintln(&buf, "}")
+	return format.Source(buf.Bytes())
+}
+
+func main() {
+	out := flag.String("o", "keysyms.go", "output file")
+	flag.Parse()
+	header := "/usr/include/X11/keysymdef.h"
+	if flag.NArg() > 0 {
+		header = flag.Arg(0)
+	}
+	keysyms, err := parse(header)
+	if err != nil {
+		log.Fatal(err)
+	}
+	src, err := generate(keysyms)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
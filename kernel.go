@@ -0,0 +1,186 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// ioctl request encoding, see include/uapi/asm-generic/ioctl.h
+const (
+	iocNone  = 0
+	iocWrite = 1
+	iocRead  = 2
+)
+
+func ioc(dir, typ, nr, size uintptr) uintptr {
+	return dir<<30 | size<<16 | typ<<8 | nr
+}
+
+func eviocgrep() uintptr          { return ioc(iocRead, 'E', 0x03, 8) }
+func eviocsrep() uintptr          { return ioc(iocWrite, 'E', 0x03, 8) }
+func eviocgled(n uintptr) uintptr { return ioc(iocRead, 'E', 0x19, n) }
+
+func ioctlPtr(fd int, req uintptr, arg unsafe.Pointer) error {
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), req, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func ioctlVal(fd int, req uintptr, arg uintptr) error {
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), req, arg)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// rawInputEvent is struct input_event as read from or written to a device
+type rawInputEvent struct {
+	Time  unix.Timeval
+	Type  uint16
+	Code  uint16
+	Value int32
+}
+
+const sizeofRawInputEvent = int(unsafe.Sizeof(rawInputEvent{}))
+
+func (raw *rawInputEvent) inputEvent() InputEvent {
+	return InputEvent{
+		Time:  time.Unix(raw.Time.Unix()),
+		Type:  raw.Type,
+		Code:  raw.Code,
+		Value: raw.Value,
+	}
+}
+
+// openInputDevice will open the given device node in non-blocking mode. The
+// device is opened for writing as well (to allow setting LEDs), unless that is
+// not permitted.
+func openInputDevice(devPath string) (int, error) {
+	fd, err := unix.Open(devPath, unix.O_RDWR|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if errors.Is(err, unix.EACCES) || errors.Is(err, unix.EROFS) {
+		fd, err = unix.Open(devPath, unix.O_RDONLY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	}
+	if err != nil {
+		return -1, &fs.PathError{Op: "open", Path: devPath, Err: err}
+	}
+	return fd, nil
+}
+
+// ledsOn will return the LED codes currently lit on the given device
+func ledsOn(fd int) ([]int, error) {
+	var leds [ledCnt / 8]byte
+	if err := ioctlPtr(fd, eviocgled(unsafe.Sizeof(leds)), unsafe.Pointer(&leds)); err != nil {
+		return nil, err
+	}
+	var on []int
+	for code := 0; code <= ledMax; code++ {
+		if leds[code/8]&(1<<(code%8)) != 0 {
+			on = append(on, code)
+		}
+	}
+	return on, nil
+}
+
+// getRepeat will return the autorepeat delay and period (in milliseconds) of
+// the given device
+func getRepeat(fd int) (int, int, error) {
+	var rep [2]uint32
+	if err := ioctlPtr(fd, eviocgrep(), unsafe.Pointer(&rep)); err != nil {
+		return 0, 0, err
+	}
+	return int(rep[0]), int(rep[1]), nil
+}
+
+// setRepeat will set the autorepeat delay and period (in milliseconds) of the
+// given device
+func setRepeat(fd int, delay, period int) error {
+	rep := [2]uint32{uint32(delay), uint32(period)}
+	return ioctlPtr(fd, eviocsrep(), unsafe.Pointer(&rep))
+}
+
+// getDevNodeRepeat is getRepeat for a device that is not already open
+func getDevNodeRepeat(devNode string) (int, int, error) {
+	fd, err := openInputDevice(devNode)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer unix.Close(fd)
+	return getRepeat(fd)
+}
+
+// setDevNodeRepeat is setRepeat for a device that is not already open
+func setDevNodeRepeat(devNode string, delay, period int) error {
+	fd, err := openInputDevice(devNode)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	return setRepeat(fd, delay, period)
+}
+
+// writeEvents will write the given events to the device with a single write,
+// stamped with the current time
+func writeEvents(fd int, events ...InputEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	raw := make([]rawInputEvent, len(events))
+	var now unix.Timeval
+	if err := unix.Gettimeofday(&now); err != nil {
+		return err
+	}
+	for i, ev := range events {
+		raw[i] = rawInputEvent{Time: now, Type: ev.Type, Code: ev.Code, Value: ev.Value}
+	}
+	buf := unsafe.Slice((*byte)(unsafe.Pointer(&raw[0])), len(raw)*sizeofRawInputEvent)
+	n, err := unix.Write(fd, buf)
+	switch {
+	case err != nil:
+		return err
+	case n != len(buf):
+		return fmt.Errorf("short write of %d of %d events", n/sizeofRawInputEvent, len(events))
+	}
+	return nil
+}
+
+// signalEventfd will make the given eventfd readable
+func signalEventfd(fd int) error {
+	var buf [8]byte
+	*(*uint64)(unsafe.Pointer(&buf[0])) = 1
+	_, err := unix.Write(fd, buf[:])
+	return err
+}
+
+// readEvent will read a single event from the device without blocking,
+// returning ErrNoEvents if there are none
+func readEvent(fd int) (InputEvent, error) {
+	var raw rawInputEvent
+	buf := unsafe.Slice((*byte)(unsafe.Pointer(&raw)), sizeofRawInputEvent)
+	for {
+		n, err := unix.Read(fd, buf)
+		switch {
+		case errors.Is(err, unix.EINTR):
+			continue
+		case errors.Is(err, unix.EAGAIN):
+			return InputEvent{}, ErrNoEvents
+		case err != nil:
+			return InputEvent{}, fmt.Errorf("could not read event: %w", err)
+		case n != sizeofRawInputEvent:
+			return InputEvent{}, fmt.Errorf("short read of %d bytes", n)
+		}
+		return raw.inputEvent(), nil
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+func Test_writeEvents(t *testing.T) {
+	var fds [2]int
+	assert.Nil(t, unix.Pipe2(fds[:], unix.O_NONBLOCK|unix.O_CLOEXEC))
+	defer unix.Close(fds[0])
+	defer unix.Close(fds[1])
+
+	want := []InputEvent{
+		{Type: evKey, Code: keyLeftShift, Value: 1},
+		{Type: evSyn, Code: synReport},
+		{Type: evKey, Code: keyLeftShift, Value: 0},
+		{Type: evSyn, Code: synReport},
+	}
+	assert.Nil(t, writeEvents(fds[1], want...))
+	assert.Nil(t, writeEvents(fds[1]))
+	for _, w := range want {
+		got, err := readEvent(fds[0])
+		assert.Nil(t, err)
+		assert.False(t, got.Time.IsZero())
+		got.Time = w.Time
+		assert.Equal(t, w, got)
+	}
+	_, err := readEvent(fds[0])
+	assert.ErrorIs(t, err, ErrNoEvents)
+
+	assert.NotNil(t, writeEvents(-1, want...))
+}
+
+func BenchmarkWriteEvents(b *testing.B) {
+	f, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+	fd := int(f.Fd())
+	// The events to type a capital letter.
+	events := make([]InputEvent, 0, 8)
+	for _, k := range chordKeys(30, keyLeftShift) {
+		events = append(events, InputEvent{Type: uint16(k.keyType), Code: uint16(k.keyCode), Value: int32(k.value)})
+	}
+	b.Run("batched", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := writeEvents(fd, events...); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("unbatched", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, ev := range events {
+				if err := writeEvents(fd, ev); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
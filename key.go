@@ -5,18 +5,18 @@
 
 package gokbd
 
-// #cgo pkg-config: libevdev
-// #include <libevdev/libevdev.h>
-// #include <libevdev/libevdev-uinput.h>
-import "C"
+import "time"
 
 type key struct {
 	keyType, keyCode, value int
+	// delay is how long to wait after sending the event, see
+	// TypingProfile
+	delay time.Duration
 }
 
 func keyPress(c int) *key {
 	return &key{
-		keyType: C.EV_KEY,
+		keyType: evKey,
 		keyCode: c,
 		value:   1,
 	}
@@ -24,7 +24,7 @@ func keyPress(c int) *key {
 
 func keyRelease(c int) *key {
 	return &key{
-		keyType: C.EV_KEY,
+		keyType: evKey,
 		keyCode: c,
 		value:   0,
 	}
@@ -32,19 +32,34 @@ func keyRelease(c int) *key {
 
 func keySync() *key {
 	return &key{
-		keyType: C.EV_SYN,
-		keyCode: C.SYN_REPORT,
+		keyType: evSyn,
+		keyCode: synReport,
 		value:   0,
 	}
 }
 
-func keySequence(keys ...*key) <-chan *key {
-	out := make(chan *key)
-	go func() {
-		for _, n := range keys {
-			out <- n
+// keyBatch is a batch of events to write at once, and how long to wait after
+// writing it
+type keyBatch struct {
+	events []InputEvent
+	delay  time.Duration
+}
+
+// keyBatches returns the events of the keys in batches to write at once,
+// split after each key with a delay
+func keyBatches(keys []*key) []keyBatch {
+	var batches []keyBatch
+	events := make([]InputEvent, 0, len(keys))
+	start := 0
+	for _, k := range keys {
+		events = append(events, InputEvent{Type: uint16(k.keyType), Code: uint16(k.keyCode), Value: int32(k.value)})
+		if k.delay > 0 {
+			batches = append(batches, keyBatch{events: events[start:], delay: k.delay})
+			start = len(events)
 		}
-		close(out)
-	}()
-	return out
+	}
+	if start < len(events) {
+		batches = append(batches, keyBatch{events: events[start:]})
+	}
+	return batches
 }
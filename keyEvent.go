@@ -5,43 +5,123 @@
 
 package gokbd
 
-// #cgo pkg-config: libevdev
-// #include <libevdev/libevdev.h>
-// #include <libevdev/libevdev-uinput.h>
-import "C"
+import (
+	"fmt"
+	"time"
+)
+
+//go:generate go run ./internal/cmd/geneventcodes -o eventcodes.go
+
+// InputEvent is a raw input event as read from or written to an input device,
+// see https://www.kernel.org/doc/html/v4.17/input/input.html#event-interface
+type InputEvent struct {
+	Time  time.Time
+	Type  uint16
+	Code  uint16
+	Value int32
+}
+
+// EventType is the type of an input event, as defined in
+// linux/input-event-codes.h
+type EventType uint16
+
+// Event types, see linux/input-event-codes.h
+const (
+	EventSyn      EventType = 0x00
+	EventKey      EventType = 0x01
+	EventRel      EventType = 0x02
+	EventAbs      EventType = 0x03
+	EventMsc      EventType = 0x04
+	EventSw       EventType = 0x05
+	EventLED      EventType = 0x11
+	EventSnd      EventType = 0x12
+	EventRep      EventType = 0x14
+	EventFF       EventType = 0x15
+	EventPwr      EventType = 0x16
+	EventFFStatus EventType = 0x17
+)
+
+// String returns the name of the event type in linux/input-event-codes.h,
+// for example EV_KEY
+func (t EventType) String() string {
+	if name := eventTypeName(uint16(t)); name != "" {
+		return name
+	}
+	return fmt.Sprintf("EventType(%d)", int(t))
+}
 
 // KeyEvent represents an event received from the keyboard
-// eventRaw is the libevdev input event, see https://www.kernel.org/doc/html/v4.17/input/input.html#event-interface
+// eventRaw is the raw input event
 // Value is the event value, for example 1 for key press, 0 for key release
+// Type is the event type, for example EventKey or EventSyn
+// Code is the key for EventKey events (for other types, the event code)
 // TypeName is the event type as a string, for example EV_KEY or EV_SYN
 // EventName is the event name as a string, for example KEY_A
 // AsRune is the key as a Go rune, for example 'a'
+// Composed is the text produced by a dead key or Compose sequence that the
+// event completes, when snooping WithCompose
+// Time is the time the kernel recorded for the event
+// Device is the keyboard that produced the event (nil if unknown)
+// Modifiers is the state of the modifier keys after the event
 type KeyEvent struct {
-	eventRaw  C.struct_input_event
+	Time      time.Time
+	Device    *KeyboardDevice
+	Modifiers KeyModifiers
+	eventRaw  InputEvent
 	Value     int
+	Type      EventType
+	Code      Key
 	TypeName  string
 	EventName string
 	AsRune    rune
+	Composed  string
 }
 
 // NewKeyEvent will create a new key event for whatever just happened on the keyboard
-func NewKeyEvent(ev C.struct_input_event) *KeyEvent {
-	return &KeyEvent{
+func NewKeyEvent(ev InputEvent) *KeyEvent {
+	kev := &KeyEvent{
+		Time:      ev.Time,
 		eventRaw:  ev,
-		Value:     int(ev.value),
-		TypeName:  C.GoString(C.libevdev_event_type_get_name(C.uint(ev._type))),
-		EventName: C.GoString(C.libevdev_event_code_get_name(C.uint(ev._type), C.uint(ev.code))),
-		AsRune:    runeMap[int(ev.code)].lc,
+		Value:     int(ev.Value),
+		Type:      EventType(ev.Type),
+		Code:      Key(ev.Code),
+		TypeName:  eventTypeName(ev.Type),
+		EventName: eventCodeName(ev.Type, ev.Code),
 	}
+	if ev.Type == evKey {
+		kev.AsRune = keyRune(int(ev.Code))
+	}
+	return kev
 }
 
-func (kev *KeyEvent) updateRune(modifiers *KeyModifiers) {
-	switch {
-	case modifiers.CapsLock:
-		fallthrough
-	case modifiers.Shift:
-		kev.AsRune = runeMap[int(kev.eventRaw.code)].uc
+func keyRune(code int) rune {
+	if r, ok := keypadOpMap[code]; ok {
+		return r
 	}
+	return DefaultLayout().Rune(code, 0)
+}
+
+// updateRune will set the rune for a key event from the layout and modifiers.
+// Only key events have a rune, the codes of other events are not keys.
+func (kev *KeyEvent) updateRune(layout Layout, modifiers *KeyModifiers) {
+	if kev.eventRaw.Type != evKey {
+		kev.AsRune = 0
+		return
+	}
+	if r, ok := keypadMap[int(kev.eventRaw.Code)]; ok {
+		// Shift inverts NumLock for the keypad, as it does on the console
+		// and under X.
+		if modifiers.NumLock != modifiers.Shift {
+			kev.AsRune = r
+		} else {
+			kev.AsRune = 0
+		}
+		return
+	}
+	if _, ok := keypadOpMap[int(kev.eventRaw.Code)]; ok {
+		return
+	}
+	kev.AsRune = layoutRune(layout, int(kev.eventRaw.Code), modifiers)
 }
 
 // IsKeyPress will return true when the event represents a key being pressed
@@ -60,6 +140,17 @@ func (kev *KeyEvent) IsKeyRelease() bool {
 	return false
 }
 
+// IsDropped will return true when the event is a notification that the kernel
+// dropped events because they were not read quickly enough. The events that
+// follow it, up to and including the next SYN_REPORT, describe the changes
+// needed to bring the keyboard state back in sync.
+func (kev *KeyEvent) IsDropped() bool {
+	if kev.TypeName == "EV_SYN" && kev.EventName == "SYN_DROPPED" {
+		return true
+	}
+	return false
+}
+
 // IsBackspace will return true when the event represents a key involved is the backspace key
 func (kev *KeyEvent) IsBackspace() bool {
 	switch kev.EventName {
@@ -14,6 +14,14 @@ func TestNewKeyEvent(t *testing.T) {
 	testNewKeyEvent(t)
 }
 
+func TestNewKeyEvent_Time(t *testing.T) {
+	testNewKeyEvent_Time(t)
+}
+
+func TestNewKeyEvent_Code(t *testing.T) {
+	testNewKeyEvent_Code(t)
+}
+
 func TestKeyEvent_updateRune(t *testing.T) {
 	testKeyEvent_updateRune(t)
 }
@@ -26,6 +34,10 @@ func TestKeyEvent_IsKeyRelease(t *testing.T) {
 	testKeyEvent_IsKeyRelease(t)
 }
 
+func TestKeyEvent_IsDropped(t *testing.T) {
+	testKeyEvent_IsDropped(t)
+}
+
 func TestKeyEvent_IsBackspace(t *testing.T) {
 	testKeyEvent_IsBackspace(t)
 }
@@ -5,13 +5,11 @@
 
 package gokbd
 
-import (
-	"C"
-)
 import (
 	"context"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -34,8 +32,12 @@ func testNewKeyEvent(t *testing.T) {
 	assert.Nil(t, err)
 	v.Close()
 
+	// NewKeyEvent has no knowledge of the device that produced the event.
+	wantKey.Device = nil
+	wantKey.Modifiers = KeyModifiers{}
+
 	type args struct {
-		ev C.struct_input_event
+		ev InputEvent
 	}
 	tests := []struct {
 		name string
@@ -57,6 +59,28 @@ func testNewKeyEvent(t *testing.T) {
 	}
 }
 
+func testNewKeyEvent_Time(t *testing.T) {
+	ev := InputEvent{Time: time.Unix(1700000000, 250000)}
+	got := NewKeyEvent(ev)
+	assert.Equal(t, time.Unix(1700000000, 250000), got.Time)
+	assert.Nil(t, got.Device)
+}
+
+func testNewKeyEvent_Code(t *testing.T) {
+	got := NewKeyEvent(InputEvent{Type: evKey, Code: keyLeftShift, Value: 1})
+	assert.Equal(t, EventKey, got.Type)
+	assert.Equal(t, KeyLeftShift, got.Code)
+	assert.Equal(t, got.TypeName, got.Type.String())
+	assert.Equal(t, got.EventName, got.Code.String())
+	got = NewKeyEvent(InputEvent{Type: evSyn, Code: synReport})
+	assert.Equal(t, EventSyn, got.Type)
+	// MSC_SCAN shares its code with KEY_3, but is not a key.
+	got = NewKeyEvent(InputEvent{Type: uint16(EventMsc), Code: 4, Value: 0x70020})
+	assert.Zero(t, got.AsRune)
+	got.updateRune(DefaultLayout(), &KeyModifiers{})
+	assert.Zero(t, got.AsRune)
+}
+
 func testKeyEvent_updateRune(t *testing.T) {
 	// This is a bit janky, but no easy way to generate the raw key event a
 	// keyboard produces without actually having a keyboard generate the key...
@@ -111,7 +135,7 @@ func testKeyEvent_updateRune(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			k := tt.fields.key
-			k.updateRune(tt.args.modifiers)
+			k.updateRune(DefaultLayout(), tt.args.modifiers)
 			assert.Equal(t, tt.want, k.AsRune)
 		})
 	}
@@ -185,6 +209,40 @@ func testKeyEvent_IsKeyRelease(t *testing.T) {
 	}
 }
 
+func testKeyEvent_IsDropped(t *testing.T) {
+	type fields struct {
+		TypeName  string
+		EventName string
+	}
+	tests := []struct {
+		name   string
+		fields fields
+		want   bool
+	}{
+		{
+			name:   "test valid",
+			fields: fields{TypeName: "EV_SYN", EventName: "SYN_DROPPED"},
+			want:   true,
+		},
+		{
+			name:   "test invalid",
+			fields: fields{TypeName: "EV_SYN", EventName: "SYN_REPORT"},
+			want:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kev := &KeyEvent{
+				TypeName:  tt.fields.TypeName,
+				EventName: tt.fields.EventName,
+			}
+			if got := kev.IsDropped(); got != tt.want {
+				t.Errorf("KeyEvent.IsDropped() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func testKeyEvent_IsBackspace(t *testing.T) {
 	type fields struct {
 		EventName string
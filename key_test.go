@@ -24,6 +24,6 @@ func Test_keySync(t *testing.T) {
 	test_keyPress(t)
 }
 
-func Test_keySequence(t *testing.T) {
-	test_keySequence(t)
+func Test_keyBatches(t *testing.T) {
+	test_keyBatches(t)
 }
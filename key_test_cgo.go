@@ -5,18 +5,15 @@
 
 package gokbd
 
-// #cgo pkg-config: libevdev
-// #include <libevdev/libevdev.h>
-// #include <libevdev/libevdev-uinput.h>
-import "C"
 import (
 	"reflect"
 	"testing"
+	"time"
 )
 
 func test_keyPress(t *testing.T) {
 	aKey := &key{
-		keyType: C.EV_KEY,
+		keyType: evKey,
 		keyCode: 30,
 		value:   1,
 	}
@@ -45,7 +42,7 @@ func test_keyPress(t *testing.T) {
 
 func test_keyRelease(t *testing.T) {
 	aKey := &key{
-		keyType: C.EV_KEY,
+		keyType: evKey,
 		keyCode: 30,
 		value:   0,
 	}
@@ -74,8 +71,8 @@ func test_keyRelease(t *testing.T) {
 
 func test_keySync(t *testing.T) {
 	k := &key{
-		keyType: C.EV_SYN,
-		keyCode: C.SYN_REPORT,
+		keyType: evSyn,
+		keyCode: synReport,
 		value:   0,
 	}
 	tests := []struct {
@@ -96,31 +93,61 @@ func test_keySync(t *testing.T) {
 	}
 }
 
-func test_keySequence(t *testing.T) {
-	keyList := []*key{
-		keyPress(30),
-		keySync(),
-		keyRelease(30),
-		keySync(),
-	}
+func test_keyBatches(t *testing.T) {
+	delayed := keySync()
+	delayed.delay = time.Millisecond
 	type args struct {
 		keys []*key
 	}
 	tests := []struct {
 		name string
 		args args
-		want []*key
+		want []keyBatch
 	}{
 		{
-			name: "test key sequence",
-			args: args{keys: keyList},
-			want: keyList,
+			name: "test single batch",
+			args: args{keys: []*key{keyPress(30), keySync(), keyRelease(30), keySync()}},
+			want: []keyBatch{
+				{events: []InputEvent{
+					{Type: evKey, Code: 30, Value: 1},
+					{Type: evSyn, Code: synReport},
+					{Type: evKey, Code: 30, Value: 0},
+					{Type: evSyn, Code: synReport},
+				}},
+			},
+		},
+		{
+			name: "test split on delay",
+			args: args{keys: []*key{keyPress(30), delayed, keyRelease(30), keySync()}},
+			want: []keyBatch{
+				{events: []InputEvent{
+					{Type: evKey, Code: 30, Value: 1},
+					{Type: evSyn, Code: synReport},
+				}, delay: time.Millisecond},
+				{events: []InputEvent{
+					{Type: evKey, Code: 30, Value: 0},
+					{Type: evSyn, Code: synReport},
+				}},
+			},
+		},
+		{
+			name: "test delay at end",
+			args: args{keys: []*key{keyPress(30), delayed}},
+			want: []keyBatch{
+				{events: []InputEvent{
+					{Type: evKey, Code: 30, Value: 1},
+					{Type: evSyn, Code: synReport},
+				}, delay: time.Millisecond},
+			},
+		},
+		{
+			name: "test no keys",
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := getValues(keySequence(tt.args.keys...)); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("keySequence() = %v, want %v", got, tt.want)
+			if got := keyBatches(tt.args.keys); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("keyBatches() = %v, want %v", got, tt.want)
 			}
 		})
 	}
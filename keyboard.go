@@ -5,38 +5,59 @@
 
 package gokbd
 
-// #cgo pkg-config: libevdev
-// #include <libevdev/libevdev.h>
-// #include <libevdev/libevdev-uinput.h>
-import "C"
 import (
 	"context"
 	"errors"
 	"fmt"
-	"io"
 	"io/fs"
-	"os"
 	"path/filepath"
 	"regexp"
-	"strings"
 	"sync"
 	"time"
-	"unicode"
 
 	"github.com/rs/zerolog/log"
+	"golang.org/x/sys/unix"
 	"kernel.org/pub/linux/libs/security/libcap/cap"
 )
 
 const devicePath = "/dev/input"
 
-func grabDevice(dev *C.struct_libevdev) (func() error, error) {
-	rv := C.libevdev_grab(dev, C.LIBEVDEV_GRAB)
-	if rv < 0 {
+var eventDeviceRegexp = regexp.MustCompile(`event\d+$`)
+
+// lockKeyLEDs maps the lock keys to the LEDs that show their state
+var lockKeyLEDs = map[int]int{
+	keyCapsLock:   ledCapsL,
+	keyNumLock:    ledNumL,
+	keyScrollLock: ledScrollL,
+}
+
+// KeyboardDevice represents a physical keyboard, it contains the source of its
+// events and state of any "modifier" keys. The identifying details of
+// the keyboard (name, vendor/product IDs, etc.) are available through the
+// embedded DeviceInfo.
+type KeyboardDevice struct {
+	DeviceInfo
+	src       EventSource
+	modifiers *KeyModifiers
+	layout    Layout
+	layoutMu  sync.Mutex
+	layoutGen int
+	levelKeys map[int]Level
+	levelGen  int
+	closeOnce sync.Once
+	// onClose, if set, is called once the keyboard is closed
+	onClose func()
+}
+
+// Grab will grab the keyboard which prevents any other clients and the kernel
+// from recieving events from it. The returned func can be used to ungrab the
+// keyboard.
+func (k *KeyboardDevice) Grab() (func() error, error) {
+	if err := k.src.Grab(true); err != nil {
 		return nil, errors.New("failed to grab device")
 	}
 	ungrab := func() error {
-		rv := C.libevdev_grab(dev, C.LIBEVDEV_UNGRAB)
-		if rv < 0 {
+		if err := k.src.Grab(false); err != nil {
 			return errors.New("failed to ungrab device")
 		}
 		return nil
@@ -44,69 +65,82 @@ func grabDevice(dev *C.struct_libevdev) (func() error, error) {
 	return ungrab, nil
 }
 
-// KeyboardDevice represents a physical keyboard, it contains the dev struct,
-// file descriptor and state of any "modifier" keys
-type KeyboardDevice struct {
-	dev       *C.struct_libevdev
-	fd        *os.File
-	modifiers *KeyModifiers
-}
-
-func (k *KeyboardDevice) Grab() (func() error, error) {
-	return grabDevice(k.dev)
-}
-
 // Close will gracefully handle closing a keyboard device, freeing memory and
-// file descriptors
+// file descriptors. It is safe to call Close more than once.
 func (k *KeyboardDevice) Close() {
-	C.libevdev_free(k.dev)
-	k.fd.Close()
+	k.closeOnce.Do(func() {
+		k.src.Close()
+		if k.onClose != nil {
+			k.onClose()
+		}
+	})
 }
 
 func (k *KeyboardDevice) isKeyboard() bool {
-	if C.libevdev_has_event_code(k.dev, C.EV_KEY, C.KEY_CAPSLOCK) == 1 {
-		return true
-	} else {
-		return false
-	}
+	return k.src.HasKey(keyCapsLock)
+}
+
+func (k *KeyboardDevice) hasKey(code int) bool {
+	return k.src.HasKey(code)
 }
 
 // OpenKeyboardDevice will open a specific keyboard device (from the device path
-// passed as a string)
+// passed as a string). The device is opened in non-blocking mode.
 func OpenKeyboardDevice(devPath string) (*KeyboardDevice, error) {
-	dev := C.libevdev_new()
-	fd, err := os.Open(devPath)
+	evdev, err := openEvdevDevice(devPath)
 	if err != nil {
 		return nil, err
 	}
-	c_err := C.libevdev_set_fd(dev, C.int(fd.Fd()))
-	if c_err > 0 {
-		return nil, errors.New("failed to init libevdev")
+	return NewKeyboardDevice(evdev), nil
+}
+
+// NewKeyboardDevice will create a keyboard device that reads its events from
+// the given source. Use OpenKeyboardDevice to open a real keyboard. The state
+// of the modifier keys is initialised from the keys already held down and
+// the lock states from the keyboard LEDs.
+func NewKeyboardDevice(src EventSource) *KeyboardDevice {
+	k := &KeyboardDevice{
+		DeviceInfo: src.Info(),
+		src:        src,
+		modifiers:  NewKeyModifers(),
+	}
+	if down, err := src.KeysDown(); err != nil {
+		log.Debug().Caller().Err(err).
+			Msg("Could not fetch initial key state.")
+	} else {
+		for _, code := range down {
+			k.modifiers.SetKey(code, true)
+		}
+		k.resetLevelKeys(k.Layout(), down)
+	}
+	if on, err := src.LEDsOn(); err != nil {
+		log.Debug().Caller().Err(err).
+			Msg("Could not fetch initial LED state.")
+	} else {
+		for _, code := range on {
+			k.modifiers.SetLock(code, true)
+		}
 	}
-	return &KeyboardDevice{
-		dev:       dev,
-		fd:        fd,
-		modifiers: NewKeyModifers(),
-	}, nil
+	return k
 }
 
 // OpenAllKeyboardDevices will open all currently connected keyboards passing
-// them out through a channel for further processing
-func OpenAllKeyboardDevices() <-chan *KeyboardDevice {
+// them out through a channel for further processing. If any filters are given,
+// only keyboards matching at least one of them are opened.
+func OpenAllKeyboardDevices(filters ...DeviceFilter) <-chan *KeyboardDevice {
 	kbdChan := make(chan *KeyboardDevice)
 	go func() {
 		for _, kbdPath := range findAllInputDevices() {
-			kbd, err := OpenKeyboardDevice(kbdPath)
+			kbd, err := openIfKeyboard(kbdPath, filters)
 			if err != nil {
 				log.Error().Err(err).
 					Msgf("Unable to open device %s.", kbdPath)
+				continue
 			}
-			if kbd.isKeyboard() {
+			if kbd != nil {
 				log.Debug().Caller().
 					Msgf("Opening keyboard device %s.", kbdPath)
 				kbdChan <- kbd
-			} else {
-				kbd.Close()
 			}
 		}
 		close(kbdChan)
@@ -116,7 +150,6 @@ func OpenAllKeyboardDevices() <-chan *KeyboardDevice {
 
 func findAllInputDevices() []string {
 	var paths []string
-	fileRegexp, _ := regexp.Compile(`event\d+$`)
 	log.Debug().Caller().
 		Msg("Looking for keyboards...")
 	err := filepath.WalkDir(devicePath, func(path string, d fs.DirEntry, err error) error {
@@ -126,7 +159,7 @@ func findAllInputDevices() []string {
 			return err
 		}
 		if !d.IsDir() {
-			if fileRegexp.MatchString(path) {
+			if eventDeviceRegexp.MatchString(path) {
 				paths = append(paths, path)
 			}
 		}
@@ -141,117 +174,147 @@ func findAllInputDevices() []string {
 	return paths
 }
 
-// SnoopAllKeyboards will snoop or listen for all key events on all currently connected keyboards.  Keyboards are passed in through a channel, see OpenKeyboardDevices for an example of opening all connected keyboards
-func SnoopAllKeyboards(ctx context.Context, kbds <-chan *KeyboardDevice) <-chan KeyEvent {
-	keys := make(chan KeyEvent, 1)
-	done := make(chan struct{})
-	for kbd := range kbds {
-		log.Debug().Caller().
-			Msgf("Tracking keys on device %s.", kbd.fd.Name())
-		go func(k *KeyboardDevice, keyCh chan KeyEvent, doneCh chan struct{}) {
-			kbdSnoop(k, keyCh, doneCh)
-		}(kbd, keys, done)
+// readEvents will read all events currently pending on the keyboard, passing
+// each to emit, until there are no more events or emit returns false. If the
+// kernel reports events were dropped (a SYN_DROPPED event), that event is
+// passed to emit so consumers know about it, then the events needed to bring
+// the keyboard state back in sync are replayed (ending with a SYN_REPORT)
+// before normal reading continues. ErrDeviceRemoved is returned if the
+// keyboard is disconnected.
+func (k *KeyboardDevice) readEvents(emit func(KeyEvent) bool) error {
+	layout, gen := k.layoutGeneration()
+	if gen != k.levelGen {
+		// The layout has changed, so the keys held down that choose a
+		// shift level may have too.
+		if down, err := k.src.KeysDown(); err != nil {
+			log.Debug().Caller().Err(err).
+				Msg("Could not fetch key state.")
+		} else {
+			k.resetLevelKeys(layout, down)
+		}
+		k.levelGen = gen
 	}
-	go func() {
-		defer close(keys)
-		defer close(done)
-		<-ctx.Done()
-	}()
-	return keys
-}
-
-// SnoopKeyboard will snoop or listen for all key events on the given keyboard
-// device.
-func SnoopKeyboard(ctx context.Context, kbd *KeyboardDevice) <-chan KeyEvent {
-	keys := make(chan KeyEvent)
-	done := make(chan struct{})
-	go func() {
-		kbdSnoop(kbd, keys, done)
-	}()
-	go func() {
-		defer close(keys)
-		defer close(done)
-		<-ctx.Done()
-	}()
-	return keys
-}
-
-func kbdSnoop(kbd *KeyboardDevice, keys chan KeyEvent, done chan struct{}) {
-	norm := C.enum_libevdev_read_flag(C.LIBEVDEV_READ_FLAG_NORMAL)
 	for {
-		var ev C.struct_input_event
-		if err := C.libevdev_next_event(kbd.dev, C.uint(norm), &ev); err != 0 {
-			log.Error().Msg("libevdev_next_event returned an error.")
+		ev, err := k.src.NextEvent()
+		if errors.Is(err, ErrNoEvents) {
+			return nil
+		}
+		if err != nil {
+			return err
 		}
 		e := NewKeyEvent(ev)
-		if e.Value != 2 {
-			switch e.EventName {
-			case "KEY_CAPSLOCK":
-				kbd.modifiers.ToggleCapsLock()
-			case "KEY_LEFTSHIFT", "KEY_RIGHTSHIFT":
-				kbd.modifiers.ToggleShift()
-			case "KEY_LEFTCTRL", "KEY_RIGHTCTRL":
-				kbd.modifiers.ToggleCtrl()
-			case "KEY_LEFTALT", "KEY_RIGHTALT":
-				kbd.modifiers.ToggleAlt()
-			case "KEY_LEFTMETA", "KEY_RIGHTMETA":
-				kbd.modifiers.ToggleMeta()
+		e.Device = k
+		switch {
+		case ev.Type == evLed:
+			k.modifiers.SetLock(int(ev.Code), ev.Value != 0)
+		case ev.Type == evKey && ev.Value != 2:
+			if led, ok := lockKeyLEDs[int(ev.Code)]; ok {
+				// Keyboards without LEDs never report the lock state, so
+				// assume each press toggles it. For keyboards with LEDs,
+				// the EV_LED event that follows has the final say.
+				if ev.Value == 1 {
+					k.modifiers.SetLock(led, !k.modifiers.lock(led))
+				}
+			} else {
+				k.modifiers.SetKey(int(ev.Code), ev.Value == 1)
+				k.setLevelKey(layout, int(ev.Code), ev.Value == 1)
 			}
 		}
-		e.updateRune(kbd.modifiers)
-		select {
-		case <-done:
-			return
-		case keys <- *e:
+		e.Modifiers = *k.modifiers
+		e.updateRune(layout, k.modifiers)
+		if !emit(*e) {
+			return nil
 		}
 	}
 }
 
 // VirtualKeyboardDevice represents a "virtual" (uinput) keyboard device
 type VirtualKeyboardDevice struct {
-	uidev   *C.struct_libevdev_uinput
-	dev     *C.struct_libevdev
-	Name    string
-	DevNode string
-	SysPath string
+	sink       EventSink
+	leds       map[LED]bool
+	ledChanges chan LEDEvent
+	ledMu      sync.Mutex
+	ledWG      sync.WaitGroup
+	layout     Layout
+	layoutMu   sync.Mutex
+	typing     typingOptions
+	typingMu   sync.Mutex
+	keys       map[Key]bool
+	held       []Key
+	releases   map[Key]chan struct{}
+	heldMu     sync.Mutex
+	writeMu    sync.Mutex
+	closeOnce  sync.Once
+	wakefd     int
+	Name       string
+	DevNode    string
+	SysPath    string
+}
+
+// VirtualKeyboardOption configures a virtual keyboard, see WithKeySets and
+// WithKeys
+type VirtualKeyboardOption func(*virtualKeyboardOptions)
+
+type virtualKeyboardOptions struct {
+	keys    []Key
+	profile TypingProfile
+}
+
+// WithKeySets will enable the keys of the given sets on the virtual keyboard.
+// The keys of KeySetLayout are always enabled.
+func WithKeySets(sets ...KeySet) VirtualKeyboardOption {
+	return func(o *virtualKeyboardOptions) {
+		for _, s := range sets {
+			o.keys = append(o.keys, s.Keys()...)
+		}
+	}
+}
+
+// WithKeys will enable the given keys on the virtual keyboard. The keys of
+// KeySetLayout are always enabled. Enabling KeyCapsLock makes the virtual
+// keyboard look like a real one, so it will be opened and snooped by
+// OpenAllKeyboardDevices and NewHotplugSnooper.
+func WithKeys(keys ...Key) VirtualKeyboardOption {
+	return func(o *virtualKeyboardOptions) {
+		o.keys = append(o.keys, keys...)
+	}
+}
+
+func newVirtualKeyboardOptions(opts []VirtualKeyboardOption) virtualKeyboardOptions {
+	o := virtualKeyboardOptions{keys: KeySetLayout.Keys()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	o.keys = sortKeys(o.keys)
+	return o
 }
 
 // NewVirtualKeyboard will create a new virtual keyboard device (with the name
-// passed in)
-func NewVirtualKeyboard(name string) (*VirtualKeyboardDevice, error) {
+// passed in). Only the keys needed to type with the built-in layouts are
+// enabled, unless others are enabled with WithKeySets or WithKeys. Typing a
+// key that is not enabled returns an error.
+func NewVirtualKeyboard(name string, opts ...VirtualKeyboardOption) (*VirtualKeyboardDevice, error) {
 	if name == "" {
 		return nil, errors.New("no name provided")
 	}
-	var uidev *C.struct_libevdev_uinput
+	o := newVirtualKeyboardOptions(opts)
 
 	uid, gid := getUserIds()
 	setIDsWithCaps(0, 0, nil)
 
-	dev := C.libevdev_new()
-	C.libevdev_set_name(dev, C.CString(name))
-	// expose the relevant event types
-	C.libevdev_enable_event_type(dev, C.EV_REL)
-	C.libevdev_enable_event_type(dev, C.EV_KEY)
-	C.libevdev_enable_event_type(dev, C.EV_REP)
-	C.libevdev_enable_event_type(dev, C.EV_SYN)
-	// expose all physical ascii keys on a standard qwerty keyboard
-	for k := range runeMap {
-		C.libevdev_enable_event_code(dev, C.EV_KEY, C.uint(k), nil)
+	codes := make([]int, 0, len(o.keys))
+	for _, k := range o.keys {
+		codes = append(codes, int(k))
 	}
 
-	// expose some modifier keys (in this case just the left ones, we only need those)
-	C.libevdev_enable_event_code(dev, C.EV_KEY, C.KEY_LEFTSHIFT, nil)
-	C.libevdev_enable_event_code(dev, C.EV_KEY, C.KEY_LEFTCTRL, nil)
-	C.libevdev_enable_event_code(dev, C.EV_KEY, C.KEY_LEFTALT, nil)
-	C.libevdev_enable_event_code(dev, C.EV_KEY, C.KEY_LEFTMETA, nil)
-
-	rv := C.libevdev_uinput_create_from_device(dev, C.LIBEVDEV_UINPUT_OPEN_MANAGED, &uidev)
-	if rv > 0 || uidev == nil {
-		return nil, errors.New("failed to create new uinput device")
+	uinput, err := createUinputDevice(name, codes, keyboardLEDs)
+	if err != nil {
+		return nil, err
 	}
+	devNode := uinput.devNode()
+	registerVirtualDevice(devNode)
 	log.Debug().Caller().
-		Msgf("Virtual keyboard created at %s.",
-			C.GoString(C.libevdev_uinput_get_devnode(uidev)))
+		Msgf("Virtual keyboard created at %s.", devNode)
 	time.Sleep(time.Millisecond * 500)
 
 	setIDsWithCaps(uid, gid, []int{getInputGroupGid()})
@@ -259,124 +322,297 @@ func NewVirtualKeyboard(name string) (*VirtualKeyboardDevice, error) {
 		return nil, fmt.Errorf("unable to drop privilege: %v", err)
 	}
 
-	return &VirtualKeyboardDevice{
-		uidev:   uidev,
-		dev:     dev,
-		Name:    name,
-		DevNode: C.GoString(C.libevdev_uinput_get_devnode(uidev)),
-		SysPath: C.GoString(C.libevdev_uinput_get_syspath(uidev)),
-	}, nil
-}
-
-func (u *VirtualKeyboardDevice) sendKeys(done <-chan struct{}, ev ...<-chan *key) <-chan error {
-	var wg sync.WaitGroup
-	out := make(chan error)
-	output := func(in <-chan *key) {
-		for k := range in {
-			select {
-			case <-done:
-				return
-			default:
-				rv := C.libevdev_uinput_write_event(u.uidev, C.uint(k.keyType), C.uint(k.keyCode), C.int(k.value))
-				if rv < 0 {
-					out <- fmt.Errorf("failed send key event type: %v code: %v value %v", k.keyType, k.keyCode, k.value)
-				}
-				time.Sleep(time.Microsecond)
-			}
-		}
-		wg.Done()
+	u, err := newVirtualKeyboardDevice(name, uinput, o)
+	if err != nil {
+		unregisterVirtualDevice(devNode)
+		uinput.Close()
+		return nil, err
 	}
+	u.DevNode = devNode
+	u.SysPath = uinput.sysPath()
+	return u, nil
+}
 
-	wg.Add(len(ev))
-	for _, c := range ev {
-		go output(c)
+// NewVirtualKeyboardWithSink will create a virtual keyboard (with the name
+// passed in) that writes its events to the given sink rather than a uinput
+// device. As there is no device node, Grab cannot be used on it. The options
+// are as for NewVirtualKeyboard.
+func NewVirtualKeyboardWithSink(name string, sink EventSink, opts ...VirtualKeyboardOption) (*VirtualKeyboardDevice, error) {
+	return newVirtualKeyboardDevice(name, sink, newVirtualKeyboardOptions(opts))
+}
+
+func newVirtualKeyboardDevice(name string, sink EventSink, opts virtualKeyboardOptions) (*VirtualKeyboardDevice, error) {
+	wakefd, err := unix.Eventfd(0, unix.EFD_NONBLOCK|unix.EFD_CLOEXEC)
+	if err != nil {
+		return nil, fmt.Errorf("could not create eventfd: %w", err)
+	}
+	u := &VirtualKeyboardDevice{
+		sink:       sink,
+		leds:       make(map[LED]bool),
+		ledChanges: make(chan LEDEvent, ledChangesSize),
+		keys:       make(map[Key]bool, len(opts.keys)),
+		wakefd:     wakefd,
+		Name:       name,
+	}
+	for _, k := range opts.keys {
+		u.keys[k] = true
 	}
+	u.typing.profile = opts.profile
+	u.ledWG.Add(1)
+	go u.watchLEDs()
+	return u, nil
+}
 
-	// Start a goroutine to close out once all the output goroutines are
-	// done.  This must start after the wg.Add call.
-	go func() {
-		wg.Wait()
-		close(out)
-	}()
-	return out
+// TypeKey will press and release the key, holding down Shift if holdShift is
+// true. The key must be enabled on the virtual keyboard.
+func (u *VirtualKeyboardDevice) TypeKey(c Key, holdShift bool) error {
+	return u.TypeKeyContext(context.Background(), c, holdShift)
 }
 
-func (u *VirtualKeyboardDevice) TypeKey(c int, holdShift bool) error {
-	done := make(chan struct{})
-	defer close(done)
+// TypeKeyContext will type the key, as TypeKey, unless the context is
+// cancelled first. Cancelling the context while the key is typed cuts the
+// typing profile's pauses short, but the key (and Shift) is still released.
+func (u *VirtualKeyboardDevice) TypeKeyContext(ctx context.Context, c Key, holdShift bool) error {
 	if holdShift {
-		errc := u.sendKeys(done, keySequence(keyPress(C.KEY_LEFTSHIFT), keySync(), keyPress(c), keySync(), keyRelease(c), keySync(), keyRelease(C.KEY_LEFTSHIFT), keySync()))
-		if err := <-errc; err != nil {
-			return err
+		return u.typeKey(ctx, int(c), LevelShift)
+	}
+	return u.typeKey(ctx, int(c), 0)
+}
+
+// Keys returns the keys enabled on the virtual keyboard, sorted by key code
+func (u *VirtualKeyboardDevice) Keys() []Key {
+	keys := make([]Key, 0, len(u.keys))
+	for k := range u.keys {
+		keys = append(keys, k)
+	}
+	return sortKeys(keys)
+}
+
+// typeKey will type the key with the modifiers for the given shift level held
+// down
+func (u *VirtualKeyboardDevice) typeKey(ctx context.Context, c int, level Level) error {
+	return u.sendKeySequence(ctx, levelKeys(c, level))
+}
+
+// levelKeys returns the key events to type the key with the modifiers for
+// the given shift level held down. Shift is typed with the left Shift key and
+// AltGr with the right Alt key.
+func levelKeys(c int, level Level) []*key {
+	var mods []int
+	if level&LevelShift != 0 {
+		mods = append(mods, keyLeftShift)
+	}
+	if level&LevelThree != 0 {
+		mods = append(mods, keyRightAlt)
+	}
+	return chordKeys(c, mods...)
+}
+
+// chordKeys returns the key events to type the key with the modifiers held
+// down, pressed in order and released in reverse
+func chordKeys(c int, mods ...int) []*key {
+	var keys []*key
+	for _, m := range mods {
+		keys = append(keys, keyPress(m), keySync())
+	}
+	keys = append(keys, keyPress(c), keySync(), keyRelease(c), keySync())
+	for i := len(mods) - 1; i >= 0; i-- {
+		keys = append(keys, keyRelease(mods[i]), keySync())
+	}
+	return keys
+}
+
+// sendKeySequence will write the key events in order, stopping at the first
+// error. Nothing is written if any of the keys is not enabled or the context
+// is already cancelled. Keys held down with Press are left held and the rest
+// paced by the typing profile.
+func (u *VirtualKeyboardDevice) sendKeySequence(ctx context.Context, keys []*key) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if k.keyType == evKey && !u.keys[Key(k.keyCode)] {
+			return fmt.Errorf("key %s is not enabled on the virtual keyboard", Key(k.keyCode))
 		}
-	} else {
-		errc := u.sendKeys(done, keySequence(keyPress(c), keySync(), keyRelease(c), keySync()))
-		if err := <-errc; err != nil {
+	}
+	// Hold the lock until written, so no other typing happens in between.
+	u.writeMu.Lock()
+	defer u.writeMu.Unlock()
+	keys = u.typingOptions().profile.pace(withoutHeld(u.Held(), keys))
+	return u.writeKeys(ctx, keys...)
+}
+
+// writeKeys will write the key events in batches, each in one go if the sink
+// is an EventBatchSink, waiting after each key with a delay (see
+// TypingProfile). The waits are cut short once the context is cancelled, but
+// every event is still written. The presses and releases of keys held down
+// with Press are left out of each batch as it is written, and Press and
+// Release can run during the waits. If a batch cannot be written, writing
+// stops and every other key that may be down (including those pressed in the
+// failed batch) is released, so no key is left stuck.
+func (u *VirtualKeyboardDevice) writeKeys(ctx context.Context, keys ...*key) error {
+	var down []Key
+	for _, b := range keyBatches(keys) {
+		events, err := u.writeUnheld(b.events, down)
+		if err != nil {
 			return err
 		}
+		down = keysDown(down, events)
+		pause(ctx, b.delay)
 	}
 	return nil
 }
 
-func (u *VirtualKeyboardDevice) TypeRune(r rune) error {
-	if !unicode.In(r, unicode.PrintRanges...) {
-		return fmt.Errorf("rune %c (%U) is not a printable character", r, r)
+// writeUnheld will write the events, less the presses and releases of keys
+// held down with Press, and return the events written. If they cannot be
+// written, the keys that may be down (those given and those pressed in the
+// events) are released, other than those held down with Press.
+func (u *VirtualKeyboardDevice) writeUnheld(events []InputEvent, down []Key) ([]InputEvent, error) {
+	u.heldMu.Lock()
+	defer u.heldMu.Unlock()
+	events = withoutHeldEvents(u.held, events)
+	if len(events) == 0 {
+		return nil, nil
 	}
-	keyCode, isUpperCase := CodeAndCase(r)
-	if keyCode == 0 {
-		return fmt.Errorf("rune %c (%U) is not in rune map", r, r)
-	} else {
-		return u.TypeKey(keyCode, isUpperCase)
+	err := u.writeEvents(events)
+	if err == nil {
+		return events, nil
+	}
+	var release []Key
+	for _, k := range keysDown(down, pressEvents(events)) {
+		if indexKey(u.held, k) < 0 {
+			release = append(release, k)
+		}
+	}
+	if len(release) == 0 {
+		return nil, err
+	}
+	return nil, errors.Join(err, u.writeEvents(releaseEvents(release)))
+}
+
+// pressEvents returns the key presses in the events
+func pressEvents(events []InputEvent) []InputEvent {
+	var presses []InputEvent
+	for _, ev := range events {
+		if ev.Type == evKey && ev.Value == 1 {
+			presses = append(presses, ev)
+		}
+	}
+	return presses
+}
+
+// keysDown returns the keys held down after the events, given those held down
+// before them, in the order they were pressed
+func keysDown(down []Key, events []InputEvent) []Key {
+	for _, ev := range events {
+		if ev.Type != evKey {
+			continue
+		}
+		switch i := indexKey(down, Key(ev.Code)); {
+		case ev.Value == 1 && i < 0:
+			down = append(down, Key(ev.Code))
+		case ev.Value == 0 && i >= 0:
+			down = append(down[:i], down[i+1:]...)
+		}
+	}
+	return down
+}
+
+// releaseEvents returns the events to release the keys, in reverse, followed
+// by a SYN_REPORT
+func releaseEvents(keys []Key) []InputEvent {
+	events := make([]InputEvent, 0, len(keys)+1)
+	for i := len(keys) - 1; i >= 0; i-- {
+		events = append(events, InputEvent{Type: evKey, Code: uint16(keys[i])})
 	}
+	return append(events, InputEvent{Type: evSyn, Code: synReport})
+}
+
+// writeEvents will write the events to the sink, in one go if it is an
+// EventBatchSink or else one at a time
+func (u *VirtualKeyboardDevice) writeEvents(events []InputEvent) error {
+	if batch, ok := u.sink.(EventBatchSink); ok {
+		if err := batch.WriteEvents(events...); err != nil {
+			return fmt.Errorf("failed to send %d key events: %w", len(events), err)
+		}
+		return nil
+	}
+	var err error
+	for _, ev := range events {
+		if writeErr := u.sink.WriteEvent(ev); writeErr != nil && err == nil {
+			err = fmt.Errorf("failed send key event type: %v code: %v value %v: %w", ev.Type, ev.Code, ev.Value, writeErr)
+		}
+	}
+	return err
+}
+
+// TypeRune will type the character with the keys of the virtual keyboard's
+// layout or, if it is not in the layout, the first fallback (see
+// SetFallbacks) that can type it
+func (u *VirtualKeyboardDevice) TypeRune(r rune) error {
+	_, err := u.TypeRuneStrategy(r)
+	return err
+}
+
+// TypeRuneContext will type the character, as TypeRune, unless the context is
+// cancelled first. Cancelling the context while the character is typed cuts
+// the typing profile's pauses short, but the character is still finished so
+// no modifier is left held down.
+func (u *VirtualKeyboardDevice) TypeRuneContext(ctx context.Context, r rune) error {
+	_, err := u.typeRune(ctx, r)
+	return err
 }
 
 // TypeSpace is a high level way to "type" a space character (effectively,
 // press/release the spacebar)
 func (u *VirtualKeyboardDevice) TypeSpace() error {
-	return u.TypeKey(C.KEY_SPACE, false)
+	return u.TypeKey(keySpace, false)
 }
 
 // TypeBackspace allows you to "type" a backspace key and remove a single
 // character
 func (u *VirtualKeyboardDevice) TypeBackspace() error {
-	return u.TypeKey(C.KEY_BACKSPACE, false)
+	return u.TypeKey(keyBackspace, false)
 }
 
 // TypeString is a high level function that makes it easy to "type" out a string
-// to the virtual keyboard
+// to the virtual keyboard. Characters not in the layout are typed with the
+// fallbacks set with SetFallbacks.
 func (u *VirtualKeyboardDevice) TypeString(str string) error {
-	s := strings.NewReader(str)
-	for {
-		r, _, err := s.ReadRune() // returns rune, nbytes, error
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return err
-		}
-		switch r {
-		case ' ':
-			err := u.TypeSpace()
-			if err != nil {
-				return err
-			}
-		default:
-			err := u.TypeRune(r)
-			if err != nil {
-				return err
-			}
-		}
-	}
-	return nil
+	_, err := u.TypeStringStrategies(str)
+	return err
+}
+
+// TypeStringContext will type the string, as TypeString, stopping between
+// characters once the context is cancelled. It returns the number of
+// characters (runes) typed, so typing can be resumed from the next one, with
+// the context's error if it was cancelled. The character being typed when the
+// context is cancelled is finished, so no modifier is left held down.
+func (u *VirtualKeyboardDevice) TypeStringContext(ctx context.Context, str string) (int, error) {
+	strategies, err := u.typeString(ctx, str)
+	return len(strategies), err
 }
 
 // Close will gracefully remove a virtual keyboard, freeing memory and file
-// descriptors
+// descriptors. Any keys held down with Press are released first. It is safe
+// to call Close more than once.
 func (u *VirtualKeyboardDevice) Close() {
-	log.Debug().Caller().
-		Msg("Closing virtual keyboard device.")
-	C.libevdev_uinput_destroy(u.uidev)
-	C.libevdev_free(u.dev)
+	u.closeOnce.Do(func() {
+		log.Debug().Caller().
+			Msg("Closing virtual keyboard device.")
+		if err := u.ReleaseAll(); err != nil {
+			log.Debug().Caller().Err(err).
+				Msg("Could not release held keys.")
+		}
+		unregisterVirtualDevice(u.DevNode)
+		if err := signalEventfd(u.wakefd); err != nil {
+			log.Debug().Caller().Err(err).
+				Msg("Could not stop watching LEDs.")
+		}
+		u.ledWG.Wait()
+		unix.Close(u.wakefd)
+		u.sink.Close()
+	})
 }
 
 // Grab will grab the virtual keyboard which prevents any other clients and the
@@ -387,5 +623,5 @@ func (u *VirtualKeyboardDevice) Grab() (func() error, error) {
 	if err != nil {
 		return nil, fmt.Errorf("could not open %s", u.Name)
 	}
-	return grabDevice(kbd.dev)
+	return kbd.Grab()
 }
@@ -0,0 +1,80 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/joshuar/gokbd"
+	"github.com/joshuar/gokbd/gokbdtest"
+	"github.com/stretchr/testify/assert"
+)
+
+// eventSink hides the WriteEvents method of a Sink, so events are written to
+// it one at a time
+type eventSink struct {
+	gokbd.EventSink
+}
+
+func TestVirtualKeyboardDevice_WriteEvents(t *testing.T) {
+	v, sink := newVirtualKeyboard(t)
+	assert.Nil(t, v.TypeRune('A'))
+	assert.Len(t, sink.Events(), 8)
+	assert.Equal(t, 1, sink.Writes())
+
+	// sinks without WriteEvents are written one event at a time
+	single, err := gokbdtest.NewSink()
+	assert.Nil(t, err)
+	w := newVirtualKeyboardWithSink(t, eventSink{single})
+	assert.Nil(t, w.TypeRune('A'))
+	assert.Equal(t, sink.Pressed(), single.Pressed())
+	assert.Equal(t, 8, single.Writes())
+}
+
+// benchmarkText returns text of about the given size, with upper and lower
+// case letters, digits, punctuation and spaces
+func benchmarkText(size int) string {
+	const line = "The Quick brown fox jumps over the lazy dog, 1234567890 times! "
+	return strings.Repeat(line, size/len(line)+1)[:size]
+}
+
+func BenchmarkVirtualKeyboardDevice_TypeString(b *testing.B) {
+	for _, size := range []int{1 << 10, 64 << 10} {
+		text := benchmarkText(size)
+		for _, bc := range []struct {
+			name string
+			sink func(*gokbdtest.Sink) gokbd.EventSink
+		}{
+			{name: "batched", sink: func(s *gokbdtest.Sink) gokbd.EventSink { return s }},
+			{name: "unbatched", sink: func(s *gokbdtest.Sink) gokbd.EventSink { return eventSink{s} }},
+		} {
+			b.Run(fmt.Sprintf("%s/%dKB", bc.name, size>>10), func(b *testing.B) {
+				sink, err := gokbdtest.NewSink()
+				if err != nil {
+					b.Fatal(err)
+				}
+				v, err := gokbd.NewVirtualKeyboardWithSink("bench", bc.sink(sink))
+				if err != nil {
+					b.Fatal(err)
+				}
+				defer v.Close()
+				b.SetBytes(int64(len(text)))
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if err := v.TypeString(text); err != nil {
+						b.Fatal(err)
+					}
+					b.StopTimer()
+					sink.Reset()
+					b.StartTimer()
+				}
+			})
+		}
+	}
+}
@@ -5,35 +5,21 @@
 
 package gokbd
 
-// #cgo pkg-config: libevdev
-// #include <libevdev/libevdev.h>
-// #include <libevdev/libevdev-uinput.h>
-import "C"
 import (
-	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
-// handy code for testing channel return from:
-// https://www.sidorenko.io/post/2019/01/testing-of-functions-with-channels-in-go/
-func getValues[V any](c <-chan V) []V {
-	var r []V
-	for i := range c {
-		r = append(r, i)
-	}
-	return r
-}
-
 func testKeyboardDevice_isKeyboard(t *testing.T) {
 	virtualKbd, err := NewVirtualKeyboard("gokbdtest")
 	assert.Nil(t, err)
 	kbds := OpenAllKeyboardDevices()
 	realKbd := <-kbds
+	notKbd, err := OpenKeyboardDevice(virtualKbd.DevNode)
+	assert.Nil(t, err)
 	type fields struct {
-		dev       *C.struct_libevdev
-		fd        *os.File
+		src       EventSource
 		modifiers *KeyModifiers
 	}
 	tests := []struct {
@@ -44,14 +30,14 @@ func testKeyboardDevice_isKeyboard(t *testing.T) {
 		{
 			name: "is a keyboard",
 			fields: fields{
-				dev: realKbd.dev,
+				src: realKbd.src,
 			},
 			want: true,
 		},
 		{
 			name: "not a keyboard",
 			fields: fields{
-				dev: virtualKbd.dev,
+				src: notKbd.src,
 			},
 			want: false,
 		},
@@ -59,8 +45,7 @@ func testKeyboardDevice_isKeyboard(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			k := &KeyboardDevice{
-				dev:       tt.fields.dev,
-				fd:        tt.fields.fd,
+				src:       tt.fields.src,
 				modifiers: tt.fields.modifiers,
 			}
 			if got := k.isKeyboard(); got != tt.want {
@@ -166,7 +151,7 @@ func testNewVirtualKeyboard(t *testing.T) {
 
 func testVirtualKeyboardDevice_TypeKey(t *testing.T) {
 	type args struct {
-		c         int
+		c         Key
 		holdShift bool
 	}
 	tests := []struct {
@@ -176,14 +161,19 @@ func testVirtualKeyboardDevice_TypeKey(t *testing.T) {
 	}{
 		{
 			name:    "test type key (no shift)",
-			args:    args{c: 30, holdShift: false},
+			args:    args{c: KeyA, holdShift: false},
 			wantErr: false,
 		},
 		{
 			name:    "test type key (shift)",
-			args:    args{c: 30, holdShift: true},
+			args:    args{c: KeyA, holdShift: true},
 			wantErr: false,
 		},
+		{
+			name:    "test type key (not enabled)",
+			args:    args{c: KeyF5},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -0,0 +1,400 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Key is a key code, as defined in linux/input-event-codes.h. Constants are
+// provided for the keys of a PC keyboard and common media and application
+// keys; any other key can be found by name with ParseKey or used by
+// converting its code, for example Key(0x1d2).
+type Key int
+
+// Key codes, see linux/input-event-codes.h
+const (
+	KeyReserved         Key = 0
+	KeyEsc              Key = 1
+	Key1                Key = 2
+	Key2                Key = 3
+	Key3                Key = 4
+	Key4                Key = 5
+	Key5                Key = 6
+	Key6                Key = 7
+	Key7                Key = 8
+	Key8                Key = 9
+	Key9                Key = 10
+	Key0                Key = 11
+	KeyMinus            Key = 12
+	KeyEqual            Key = 13
+	KeyBackspace        Key = 14
+	KeyTab              Key = 15
+	KeyQ                Key = 16
+	KeyW                Key = 17
+	KeyE                Key = 18
+	KeyR                Key = 19
+	KeyT                Key = 20
+	KeyY                Key = 21
+	KeyU                Key = 22
+	KeyI                Key = 23
+	KeyO                Key = 24
+	KeyP                Key = 25
+	KeyLeftBrace        Key = 26
+	KeyRightBrace       Key = 27
+	KeyEnter            Key = 28
+	KeyLeftCtrl         Key = 29
+	KeyA                Key = 30
+	KeyS                Key = 31
+	KeyD                Key = 32
+	KeyF                Key = 33
+	KeyG                Key = 34
+	KeyH                Key = 35
+	KeyJ                Key = 36
+	KeyK                Key = 37
+	KeyL                Key = 38
+	KeySemicolon        Key = 39
+	KeyApostrophe       Key = 40
+	KeyGrave            Key = 41
+	KeyLeftShift        Key = 42
+	KeyBackslash        Key = 43
+	KeyZ                Key = 44
+	KeyX                Key = 45
+	KeyC                Key = 46
+	KeyV                Key = 47
+	KeyB                Key = 48
+	KeyN                Key = 49
+	KeyM                Key = 50
+	KeyComma            Key = 51
+	KeyDot              Key = 52
+	KeySlash            Key = 53
+	KeyRightShift       Key = 54
+	KeyKPAsterisk       Key = 55
+	KeyLeftAlt          Key = 56
+	KeySpace            Key = 57
+	KeyCapsLock         Key = 58
+	KeyF1               Key = 59
+	KeyF2               Key = 60
+	KeyF3               Key = 61
+	KeyF4               Key = 62
+	KeyF5               Key = 63
+	KeyF6               Key = 64
+	KeyF7               Key = 65
+	KeyF8               Key = 66
+	KeyF9               Key = 67
+	KeyF10              Key = 68
+	KeyNumLock          Key = 69
+	KeyScrollLock       Key = 70
+	KeyKP7              Key = 71
+	KeyKP8              Key = 72
+	KeyKP9              Key = 73
+	KeyKPMinus          Key = 74
+	KeyKP4              Key = 75
+	KeyKP5              Key = 76
+	KeyKP6              Key = 77
+	KeyKPPlus           Key = 78
+	KeyKP1              Key = 79
+	KeyKP2              Key = 80
+	KeyKP3              Key = 81
+	KeyKP0              Key = 82
+	KeyKPDot            Key = 83
+	KeyZenkakuHankaku   Key = 85
+	Key102nd            Key = 86
+	KeyF11              Key = 87
+	KeyF12              Key = 88
+	KeyRO               Key = 89
+	KeyKatakana         Key = 90
+	KeyHiragana         Key = 91
+	KeyHenkan           Key = 92
+	KeyKatakanaHiragana Key = 93
+	KeyMuhenkan         Key = 94
+	KeyKPJPComma        Key = 95
+	KeyKPEnter          Key = 96
+	KeyRightCtrl        Key = 97
+	KeyKPSlash          Key = 98
+	KeySysRq            Key = 99
+	KeyRightAlt         Key = 100
+	KeyLineFeed         Key = 101
+	KeyHome             Key = 102
+	KeyUp               Key = 103
+	KeyPageUp           Key = 104
+	KeyLeft             Key = 105
+	KeyRight            Key = 106
+	KeyEnd              Key = 107
+	KeyDown             Key = 108
+	KeyPageDown         Key = 109
+	KeyInsert           Key = 110
+	KeyDelete           Key = 111
+	KeyMacro            Key = 112
+	KeyMute             Key = 113
+	KeyVolumeDown       Key = 114
+	KeyVolumeUp         Key = 115
+	KeyPower            Key = 116
+	KeyKPEqual          Key = 117
+	KeyKPPlusMinus      Key = 118
+	KeyPause            Key = 119
+	KeyScale            Key = 120
+	KeyKPComma          Key = 121
+	KeyHangeul          Key = 122
+	KeyHanja            Key = 123
+	KeyYen              Key = 124
+	KeyLeftMeta         Key = 125
+	KeyRightMeta        Key = 126
+	KeyCompose          Key = 127
+	KeyStop             Key = 128
+	KeyAgain            Key = 129
+	KeyProps            Key = 130
+	KeyUndo             Key = 131
+	KeyFront            Key = 132
+	KeyCopy             Key = 133
+	KeyOpen             Key = 134
+	KeyPaste            Key = 135
+	KeyFind             Key = 136
+	KeyCut              Key = 137
+	KeyHelp             Key = 138
+	KeyMenu             Key = 139
+	KeyCalc             Key = 140
+	KeySleep            Key = 142
+	KeyWakeUp           Key = 143
+	KeyWWW              Key = 150
+	KeyMail             Key = 155
+	KeyBookmarks        Key = 156
+	KeyComputer         Key = 157
+	KeyBack             Key = 158
+	KeyForward          Key = 159
+	KeyEjectCD          Key = 161
+	KeyNextSong         Key = 163
+	KeyPlayPause        Key = 164
+	KeyPreviousSong     Key = 165
+	KeyStopCD           Key = 166
+	KeyRecord           Key = 167
+	KeyRewind           Key = 168
+	KeyPhone            Key = 169
+	KeyConfig           Key = 171
+	KeyHomePage         Key = 172
+	KeyRefresh          Key = 173
+	KeyExit             Key = 174
+	KeyScrollUp         Key = 177
+	KeyScrollDown       Key = 178
+	KeyKPLeftParen      Key = 179
+	KeyKPRightParen     Key = 180
+	KeyF13              Key = 183
+	KeyF14              Key = 184
+	KeyF15              Key = 185
+	KeyF16              Key = 186
+	KeyF17              Key = 187
+	KeyF18              Key = 188
+	KeyF19              Key = 189
+	KeyF20              Key = 190
+	KeyF21              Key = 191
+	KeyF22              Key = 192
+	KeyF23              Key = 193
+	KeyF24              Key = 194
+	KeyPlayCD           Key = 200
+	KeyPauseCD          Key = 201
+	KeyPlay             Key = 207
+	KeyFastForward      Key = 208
+	KeyPrint            Key = 210
+	KeyCamera           Key = 212
+	KeySearch           Key = 217
+	KeyBrightnessDown   Key = 224
+	KeyBrightnessUp     Key = 225
+	KeyMedia            Key = 226
+	KeyMicMute          Key = 248
+)
+
+// keyAliases are the names ParseKey accepts besides those of
+// linux/input-event-codes.h, in lower case. Modifiers without a side are the
+// left one, and characters are the key for them on a US QWERTY keyboard.
+var keyAliases = map[string]Key{
+	"ctrl":        KeyLeftCtrl,
+	"control":     KeyLeftCtrl,
+	"lctrl":       KeyLeftCtrl,
+	"rctrl":       KeyRightCtrl,
+	"shift":       KeyLeftShift,
+	"lshift":      KeyLeftShift,
+	"rshift":      KeyRightShift,
+	"alt":         KeyLeftAlt,
+	"lalt":        KeyLeftAlt,
+	"ralt":        KeyRightAlt,
+	"altgr":       KeyRightAlt,
+	"meta":        KeyLeftMeta,
+	"super":       KeyLeftMeta,
+	"win":         KeyLeftMeta,
+	"cmd":         KeyLeftMeta,
+	"lmeta":       KeyLeftMeta,
+	"rmeta":       KeyRightMeta,
+	"esc":         KeyEsc,
+	"escape":      KeyEsc,
+	"return":      KeyEnter,
+	"bksp":        KeyBackspace,
+	"del":         KeyDelete,
+	"ins":         KeyInsert,
+	"pgup":        KeyPageUp,
+	"pgdn":        KeyPageDown,
+	"caps":        KeyCapsLock,
+	"printscreen": KeySysRq,
+	"prtsc":       KeySysRq,
+	"break":       KeyPause,
+	"-":           KeyMinus,
+	"=":           KeyEqual,
+	"[":           KeyLeftBrace,
+	"]":           KeyRightBrace,
+	";":           KeySemicolon,
+	"'":           KeyApostrophe,
+	"`":           KeyGrave,
+	"\\":          KeyBackslash,
+	",":           KeyComma,
+	".":           KeyDot,
+	"/":           KeySlash,
+}
+
+// keyNames maps the names in linux/input-event-codes.h to their key codes
+var keyNames = func() map[string]Key {
+	names := make(map[string]Key, len(eventCodeNames[evKey])+len(keyNameAliases))
+	for code, name := range eventCodeNames[evKey] {
+		names[name] = Key(code)
+	}
+	for name, code := range keyNameAliases {
+		names[name] = Key(code)
+	}
+	return names
+}()
+
+// String returns the name of the key in linux/input-event-codes.h, for
+// example KEY_A
+func (k Key) String() string {
+	if k >= 0 && k <= keyMax {
+		if name := eventCodeName(evKey, uint16(k)); name != "" {
+			return name
+		}
+	}
+	return fmt.Sprintf("Key(%d)", int(k))
+}
+
+// ParseKey will return the key with the given name, ignoring case. The name
+// can be as in linux/input-event-codes.h, with or without the KEY_ prefix
+// (for example "KEY_LEFTCTRL", "leftctrl" or "f5"), a character on a US
+// QWERTY keyboard (for example "a", "1" or "/") or a common name such as
+// "ctrl", "alt", "super", "esc" or "pgup".
+func ParseKey(name string) (Key, error) {
+	if k, ok := keyAliases[strings.ToLower(name)]; ok {
+		return k, nil
+	}
+	upper := strings.ToUpper(name)
+	if k, ok := keyNames[upper]; ok {
+		return k, nil
+	}
+	if k, ok := keyNames["KEY_"+upper]; ok {
+		return k, nil
+	}
+	return 0, fmt.Errorf("unknown key %q", name)
+}
+
+// KeySet is a preset group of keys that can be enabled on a virtual keyboard,
+// see WithKeySets
+type KeySet int
+
+const (
+	// KeySetLayout is the keys needed to type with the built-in layouts: the
+	// keys of each layout, the left modifiers and AltGr, and the keys used by
+	// the typing fallbacks. It is always enabled.
+	KeySetLayout KeySet = iota
+	// KeySetFull is every key of a full size (104 or 105 key) PC keyboard,
+	// including the function, navigation and keypad keys, except Caps Lock.
+	// A device with Caps Lock is taken to be a keyboard, so a virtual
+	// keyboard with it would be opened and snooped like a real one.
+	KeySetFull
+	// KeySetNumpad is the keypad keys and Num Lock.
+	KeySetNumpad
+	// KeySetMedia is the volume and media playback keys.
+	KeySetMedia
+)
+
+func (s KeySet) String() string {
+	switch s {
+	case KeySetLayout:
+		return "KeySetLayout"
+	case KeySetFull:
+		return "KeySetFull"
+	case KeySetNumpad:
+		return "KeySetNumpad"
+	case KeySetMedia:
+		return "KeySetMedia"
+	default:
+		return fmt.Sprintf("KeySet(%d)", int(s))
+	}
+}
+
+// numpadKeys are the keys of KeySetNumpad
+var numpadKeys = []Key{
+	KeyNumLock, KeyKPSlash, KeyKPAsterisk, KeyKPMinus,
+	KeyKP7, KeyKP8, KeyKP9, KeyKPPlus,
+	KeyKP4, KeyKP5, KeyKP6,
+	KeyKP1, KeyKP2, KeyKP3, KeyKPEnter,
+	KeyKP0, KeyKPDot,
+}
+
+// fullKeys are the keys of KeySetFull, less the keypad (and Caps Lock, see
+// KeyboardDevice.isKeyboard)
+var fullKeys = []Key{
+	KeyEsc, KeyF1, KeyF2, KeyF3, KeyF4, KeyF5, KeyF6, KeyF7, KeyF8, KeyF9,
+	KeyF10, KeyF11, KeyF12, KeySysRq, KeyScrollLock, KeyPause,
+	KeyGrave, Key1, Key2, Key3, Key4, Key5, Key6, Key7, Key8, Key9, Key0,
+	KeyMinus, KeyEqual, KeyBackspace,
+	KeyTab, KeyQ, KeyW, KeyE, KeyR, KeyT, KeyY, KeyU, KeyI, KeyO, KeyP,
+	KeyLeftBrace, KeyRightBrace, KeyBackslash,
+	KeyA, KeyS, KeyD, KeyF, KeyG, KeyH, KeyJ, KeyK, KeyL,
+	KeySemicolon, KeyApostrophe, KeyEnter,
+	KeyLeftShift, Key102nd, KeyZ, KeyX, KeyC, KeyV, KeyB, KeyN, KeyM,
+	KeyComma, KeyDot, KeySlash, KeyRightShift,
+	KeyLeftCtrl, KeyLeftMeta, KeyLeftAlt, KeySpace, KeyRightAlt,
+	KeyRightMeta, KeyCompose, KeyRightCtrl,
+	KeyInsert, KeyHome, KeyPageUp, KeyDelete, KeyEnd, KeyPageDown,
+	KeyUp, KeyLeft, KeyDown, KeyRight,
+}
+
+// mediaKeys are the keys of KeySetMedia
+var mediaKeys = []Key{
+	KeyMute, KeyVolumeDown, KeyVolumeUp, KeyMicMute,
+	KeyPlayPause, KeyPlay, KeyPauseCD, KeyStopCD, KeyNextSong,
+	KeyPreviousSong, KeyRewind, KeyFastForward, KeyRecord, KeyEjectCD,
+}
+
+// Keys returns the keys in the set, sorted by key code
+func (s KeySet) Keys() []Key {
+	var keys []Key
+	switch s {
+	case KeySetLayout:
+		for _, code := range layoutCodes() {
+			keys = append(keys, Key(code))
+		}
+		keys = append(keys, KeyLeftShift, KeyLeftCtrl, KeyLeftAlt, KeyLeftMeta, KeyRightAlt)
+		keys = append(keys, KeyCompose, keyRemap)
+	case KeySetFull:
+		keys = append(keys, fullKeys...)
+		keys = append(keys, numpadKeys...)
+	case KeySetNumpad:
+		keys = append(keys, numpadKeys...)
+	case KeySetMedia:
+		keys = append(keys, mediaKeys...)
+	}
+	return sortKeys(keys)
+}
+
+// sortKeys will sort the keys by key code, removing any duplicates
+func sortKeys(keys []Key) []Key {
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	var out []Key
+	for i, k := range keys {
+		if i == 0 || k != keys[i-1] {
+			out = append(out, k)
+		}
+	}
+	return out
+}
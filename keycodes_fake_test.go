@@ -0,0 +1,27 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd_test
+
+import (
+	"testing"
+
+	"github.com/joshuar/gokbd"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVirtualKeyboardDevice_KeySets(t *testing.T) {
+	v, sink := newVirtualKeyboard(t,
+		gokbd.WithKeySets(gokbd.KeySetMedia), gokbd.WithKeys(gokbd.KeyF5))
+	assert.Contains(t, v.Keys(), gokbd.KeyVolumeUp)
+	assert.Contains(t, v.Keys(), gokbd.KeyA)
+	assert.NotContains(t, v.Keys(), gokbd.KeyUp)
+
+	assert.Nil(t, v.TypeKey(gokbd.KeyVolumeUp, false))
+	assert.Nil(t, v.TypeKey(gokbd.KeyF5, false))
+	assert.Nil(t, v.TypeKey(gokbd.KeyA, true))
+	assert.NotNil(t, v.TypeKey(gokbd.KeyUp, false))
+	assert.Equal(t, pressed(gokbd.KeyVolumeUp, gokbd.KeyF5, gokbd.KeyLeftShift, gokbd.KeyA), sink.Pressed())
+}
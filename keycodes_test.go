@@ -0,0 +1,153 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKey_codes(t *testing.T) {
+	// The constants should match the kernel names.
+	for k, want := range map[Key]string{
+		KeyEsc:          "KEY_ESC",
+		Key0:            "KEY_0",
+		KeyA:            "KEY_A",
+		KeyEnter:        "KEY_ENTER",
+		KeyF5:           "KEY_F5",
+		KeyF12:          "KEY_F12",
+		KeyF24:          "KEY_F24",
+		Key102nd:        "KEY_102ND",
+		KeyKPEnter:      "KEY_KPENTER",
+		KeyUp:           "KEY_UP",
+		KeyPageDown:     "KEY_PAGEDOWN",
+		KeyCompose:      "KEY_COMPOSE",
+		KeyVolumeUp:     "KEY_VOLUMEUP",
+		KeyPlayPause:    "KEY_PLAYPAUSE",
+		KeyBrightnessUp: "KEY_BRIGHTNESSUP",
+		KeyMicMute:      "KEY_MICMUTE",
+	} {
+		assert.Equal(t, want, eventCodeName(evKey, uint16(k)))
+	}
+}
+
+func TestKeySet_Keys(t *testing.T) {
+	tests := []struct {
+		set     KeySet
+		want    []Key
+		notWant []Key
+		wantLen int
+	}{
+		{
+			set:     KeySetLayout,
+			want:    []Key{KeyA, KeyEqual, Key102nd, KeyLeftShift, KeyRightAlt, KeyCompose, KeyF24},
+			notWant: []Key{KeyF5, KeyUp, KeyVolumeUp},
+		},
+		{
+			set:     KeySetFull,
+			want:    []Key{KeyEsc, KeyF5, KeyUp, KeyHome, KeyKPEnter, KeyRightCtrl, KeySysRq},
+			notWant: []Key{KeyVolumeUp, KeyF13, KeyCapsLock},
+			wantLen: 104,
+		},
+		{
+			set:     KeySetNumpad,
+			want:    []Key{KeyNumLock, KeyKP0, KeyKPEnter},
+			notWant: []Key{KeyA},
+			wantLen: 17,
+		},
+		{
+			set:     KeySetMedia,
+			want:    []Key{KeyMute, KeyVolumeUp, KeyPlayPause, KeyNextSong},
+			notWant: []Key{KeyA},
+		},
+		{
+			set: KeySet(9),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.set.String(), func(t *testing.T) {
+			got := tt.set.Keys()
+			for _, k := range tt.want {
+				assert.Contains(t, got, k)
+			}
+			for _, k := range tt.notWant {
+				assert.NotContains(t, got, k)
+			}
+			if tt.wantLen != 0 {
+				assert.Len(t, got, tt.wantLen)
+			}
+			for i := 1; i < len(got); i++ {
+				assert.Less(t, got[i-1], got[i])
+			}
+			for _, k := range got {
+				assert.NotEmpty(t, eventCodeName(evKey, uint16(k)), "key %d", k)
+			}
+		})
+	}
+}
+
+func TestKey_String(t *testing.T) {
+	assert.Equal(t, "KEY_A", KeyA.String())
+	assert.Equal(t, "KEY_LEFTCTRL", KeyLeftCtrl.String())
+	assert.Equal(t, "BTN_LEFT", Key(0x110).String())
+	assert.Equal(t, "Key(767)", Key(0x2ff).String())
+	assert.Equal(t, "Key(-1)", Key(-1).String())
+}
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Key
+		wantErr bool
+	}{
+		{name: "KEY_LEFTCTRL", want: KeyLeftCtrl},
+		{name: "leftctrl", want: KeyLeftCtrl},
+		{name: "ctrl", want: KeyLeftCtrl},
+		{name: "Ctrl", want: KeyLeftCtrl},
+		{name: "altgr", want: KeyRightAlt},
+		{name: "super", want: KeyLeftMeta},
+		{name: "a", want: KeyA},
+		{name: "A", want: KeyA},
+		{name: "1", want: Key1},
+		{name: "/", want: KeySlash},
+		{name: "f5", want: KeyF5},
+		{name: "F24", want: KeyF24},
+		{name: "esc", want: KeyEsc},
+		{name: "pgup", want: KeyPageUp},
+		{name: "volumeup", want: KeyVolumeUp},
+		{name: "BTN_LEFT", want: Key(0x110)},
+		{name: "KEY_SCREENLOCK", want: Key(0x98)},
+		{name: "screenlock", want: Key(0x98)},
+		{name: "KEY_NOTHING", wantErr: true},
+		{name: "", wantErr: true},
+		{name: "SYN_REPORT", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseKey(tt.name)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+	// Every key name should parse back to its key.
+	for code, name := range eventCodeNames[evKey] {
+		got, err := ParseKey(name)
+		assert.Nil(t, err)
+		assert.Equal(t, Key(code), got, name)
+	}
+}
+
+func TestEventType_String(t *testing.T) {
+	assert.Equal(t, "EV_KEY", EventKey.String())
+	assert.Equal(t, "EV_SYN", EventSyn.String())
+	assert.Equal(t, "EV_FF_STATUS", EventFFStatus.String())
+	assert.Equal(t, "EventType(99)", EventType(99).String())
+}
@@ -5,13 +5,122 @@
 
 package gokbd
 
-// KeyModifiers represents the state of any "modifier" keys on the keyboard
+// KeyModifiers represents the state of any "modifier" keys on the keyboard.
+// Each modifier key is tracked individually (LeftShift, RightShift, etc.),
+// while Alt, Ctrl, Shift and Meta are true when either side is held down.
+// RightAlt is the AltGr key on most layouts. Level3 and Level5 are true when a
+// key the keyboard's layout uses to choose the third (AltGr) or fifth shift
+// level is held down. The lock states (CapsLock, NumLock, ScrollLock, Compose
+// and Kana) follow the keyboard LEDs.
 type KeyModifiers struct {
-	CapsLock bool
-	Alt      bool
-	Ctrl     bool
-	Shift    bool
-	Meta     bool
+	CapsLock   bool
+	NumLock    bool
+	ScrollLock bool
+	Compose    bool
+	Kana       bool
+	Alt        bool
+	Ctrl       bool
+	Shift      bool
+	Meta       bool
+	LeftShift  bool
+	RightShift bool
+	LeftCtrl   bool
+	RightCtrl  bool
+	LeftAlt    bool
+	RightAlt   bool
+	LeftMeta   bool
+	RightMeta  bool
+	Level3     bool
+	Level5     bool
+}
+
+// SetKey will record whether the given modifier key code is held down, and
+// returns false if the key code is not a modifier (in which case nothing is
+// changed). Unlike the Toggle methods, this stays correct if a press or
+// release is missed or both sides are pressed together.
+func (km *KeyModifiers) SetKey(code int, down bool) bool {
+	switch code {
+	case keyLeftShift:
+		km.LeftShift = down
+	case keyRightShift:
+		km.RightShift = down
+	case keyLeftCtrl:
+		km.LeftCtrl = down
+	case keyRightCtrl:
+		km.RightCtrl = down
+	case keyLeftAlt:
+		km.LeftAlt = down
+	case keyRightAlt:
+		km.RightAlt = down
+	case keyLeftMeta:
+		km.LeftMeta = down
+	case keyRightMeta:
+		km.RightMeta = down
+	default:
+		return false
+	}
+	km.Shift = km.LeftShift || km.RightShift
+	km.Ctrl = km.LeftCtrl || km.RightCtrl
+	km.Alt = km.LeftAlt || km.RightAlt
+	km.Meta = km.LeftMeta || km.RightMeta
+	return true
+}
+
+// SetLock will record whether the lock represented by the given LED code is
+// on, and returns false if the LED code is not a known lock (in which case
+// nothing is changed)
+func (km *KeyModifiers) SetLock(led int, on bool) bool {
+	switch led {
+	case ledCapsL:
+		km.CapsLock = on
+	case ledNumL:
+		km.NumLock = on
+	case ledScrollL:
+		km.ScrollLock = on
+	case ledCompose:
+		km.Compose = on
+	case ledKana:
+		km.Kana = on
+	default:
+		return false
+	}
+	return true
+}
+
+func (km *KeyModifiers) lock(led int) bool {
+	switch led {
+	case ledCapsL:
+		return km.CapsLock
+	case ledNumL:
+		return km.NumLock
+	case ledScrollL:
+		return km.ScrollLock
+	case ledCompose:
+		return km.Compose
+	case ledKana:
+		return km.Kana
+	}
+	return false
+}
+
+// AltGr returns true when the AltGr (right Alt) key is held down
+func (km *KeyModifiers) AltGr() bool {
+	return km.RightAlt
+}
+
+// Level returns the shift level chosen by the modifiers held down
+func (km *KeyModifiers) Level() Level {
+	var level Level
+	if km.Shift {
+		level |= LevelShift
+	}
+	if km.Level3 {
+		level |= LevelThree
+	}
+	if km.Level5 {
+		level |= LevelFive
+	}
+	return level
 }
 
 // ToggleAlt keeps track of whether an Alt key has been pressed
@@ -0,0 +1,78 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd_test
+
+import (
+	"testing"
+
+	"github.com/joshuar/gokbd"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyboardDevice_Modifiers(t *testing.T) {
+	fake := newKeyboard(t)
+	// opened with shift already held
+	fake.Hold(int(gokbd.KeyLeftShift))
+	keys := snoop(t, gokbd.NewKeyboardDevice(fake)).Keys()
+
+	fake.Tap(int(gokbd.KeyA))
+	ev := nextKeyPress(t, keys)
+	assert.Equal(t, 'A', ev.AsRune)
+	assert.True(t, ev.Modifiers.LeftShift)
+	assert.False(t, ev.Modifiers.RightShift)
+
+	// pressing both shifts and releasing one keeps shift held
+	fake.Press(int(gokbd.KeyRightShift))
+	nextKeyPress(t, keys)
+	fake.Release(int(gokbd.KeyLeftShift))
+	fake.Tap(int(gokbd.KeyA))
+	ev = nextKeyPress(t, keys)
+	assert.Equal(t, 'A', ev.AsRune)
+	assert.False(t, ev.Modifiers.LeftShift)
+	assert.True(t, ev.Modifiers.RightShift)
+
+	fake.Release(int(gokbd.KeyRightShift))
+	fake.Tap(int(gokbd.KeyA))
+	ev = nextKeyPress(t, keys)
+	assert.Equal(t, 'a', ev.AsRune)
+	assert.False(t, ev.Modifiers.Shift)
+}
+
+func TestKeyboardDevice_Locks(t *testing.T) {
+	fake := newKeyboard(t)
+	// opened with caps lock already on
+	fake.Light(int(gokbd.LEDCapsLock))
+	keys := snoop(t, gokbd.NewKeyboardDevice(fake)).Keys()
+
+	fake.Tap(int(gokbd.KeyA))
+	ev := nextKeyPress(t, keys)
+	assert.Equal(t, 'A', ev.AsRune)
+	assert.True(t, ev.Modifiers.CapsLock)
+	assert.False(t, ev.Modifiers.NumLock)
+
+	// with num lock off, the keypad is used for navigation
+	fake.Tap(int(gokbd.KeyKP7))
+	assert.Equal(t, rune(0), nextKeyPress(t, keys).AsRune)
+	fake.Tap(int(gokbd.KeyKPPlus))
+	assert.Equal(t, '+', nextKeyPress(t, keys).AsRune)
+
+	// the LED has the final say on the lock state
+	fake.Tap(int(gokbd.KeyNumLock))
+	nextKeyPress(t, keys)
+	assert.Nil(t, fake.SetLED(int(gokbd.LEDNumLock), true))
+	fake.Tap(int(gokbd.KeyKP7))
+	ev = nextKeyPress(t, keys)
+	assert.True(t, ev.Modifiers.NumLock)
+	assert.Equal(t, '7', ev.AsRune)
+
+	fake.Tap(int(gokbd.KeyCapsLock))
+	nextKeyPress(t, keys)
+	assert.Nil(t, fake.SetLED(int(gokbd.LEDCapsLock), false))
+	fake.Tap(int(gokbd.KeyA))
+	ev = nextKeyPress(t, keys)
+	assert.False(t, ev.Modifiers.CapsLock)
+	assert.Equal(t, 'a', ev.AsRune)
+}
@@ -211,3 +211,173 @@ func TestNewKeyModifers(t *testing.T) {
 		})
 	}
 }
+
+func TestKeyModifiers_SetKey(t *testing.T) {
+	type key struct {
+		code int
+		down bool
+	}
+	tests := []struct {
+		name     string
+		keys     []key
+		want     KeyModifiers
+		wantMods bool
+	}{
+		{
+			name:     "left shift down",
+			keys:     []key{{code: keyLeftShift, down: true}},
+			want:     KeyModifiers{Shift: true, LeftShift: true},
+			wantMods: true,
+		},
+		{
+			name: "both shifts, one released",
+			keys: []key{
+				{code: keyLeftShift, down: true},
+				{code: keyRightShift, down: true},
+				{code: keyLeftShift, down: false},
+			},
+			want:     KeyModifiers{Shift: true, RightShift: true},
+			wantMods: true,
+		},
+		{
+			name: "missed press",
+			keys: []key{
+				{code: keyLeftCtrl, down: false},
+				{code: keyLeftCtrl, down: false},
+			},
+			want:     KeyModifiers{},
+			wantMods: true,
+		},
+		{
+			name:     "altgr",
+			keys:     []key{{code: keyRightAlt, down: true}},
+			want:     KeyModifiers{Alt: true, RightAlt: true},
+			wantMods: true,
+		},
+		{
+			name: "meta",
+			keys: []key{
+				{code: keyLeftMeta, down: true},
+				{code: keyRightMeta, down: true},
+			},
+			want:     KeyModifiers{Meta: true, LeftMeta: true, RightMeta: true},
+			wantMods: true,
+		},
+		{
+			name:     "not a modifier",
+			keys:     []key{{code: 30, down: true}},
+			want:     KeyModifiers{},
+			wantMods: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			km := NewKeyModifers()
+			var got bool
+			for _, k := range tt.keys {
+				got = km.SetKey(k.code, k.down)
+			}
+			assert.Equal(t, tt.wantMods, got)
+			assert.Equal(t, tt.want, *km)
+			assert.Equal(t, tt.want.RightAlt, km.AltGr())
+		})
+	}
+}
+
+func TestKeyModifiers_SetLock(t *testing.T) {
+	tests := []struct {
+		name      string
+		led       int
+		on        bool
+		want      KeyModifiers
+		wantLocks bool
+	}{
+		{
+			name:      "caps lock on",
+			led:       ledCapsL,
+			on:        true,
+			want:      KeyModifiers{CapsLock: true},
+			wantLocks: true,
+		},
+		{
+			name:      "num lock on",
+			led:       ledNumL,
+			on:        true,
+			want:      KeyModifiers{NumLock: true},
+			wantLocks: true,
+		},
+		{
+			name:      "scroll lock off",
+			led:       ledScrollL,
+			on:        false,
+			want:      KeyModifiers{},
+			wantLocks: true,
+		},
+		{
+			name:      "compose on",
+			led:       ledCompose,
+			on:        true,
+			want:      KeyModifiers{Compose: true},
+			wantLocks: true,
+		},
+		{
+			name:      "kana on",
+			led:       ledKana,
+			on:        true,
+			want:      KeyModifiers{Kana: true},
+			wantLocks: true,
+		},
+		{
+			name:      "not a lock",
+			led:       0x08,
+			on:        true,
+			want:      KeyModifiers{},
+			wantLocks: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			km := NewKeyModifers()
+			assert.Equal(t, tt.wantLocks, km.SetLock(tt.led, tt.on))
+			assert.Equal(t, tt.want, *km)
+			assert.Equal(t, tt.on && tt.wantLocks, km.lock(tt.led))
+		})
+	}
+}
+
+func TestKeyModifiers_Level(t *testing.T) {
+	tests := []struct {
+		name      string
+		modifiers KeyModifiers
+		want      Level
+	}{
+		{
+			name: "none",
+		},
+		{
+			name:      "shift",
+			modifiers: KeyModifiers{Shift: true},
+			want:      LevelShift,
+		},
+		{
+			name:      "altgr",
+			modifiers: KeyModifiers{Level3: true, RightAlt: true, Alt: true},
+			want:      LevelThree,
+		},
+		{
+			name:      "right alt without altgr",
+			modifiers: KeyModifiers{RightAlt: true, Alt: true},
+			want:      0,
+		},
+		{
+			name:      "all",
+			modifiers: KeyModifiers{Shift: true, Level3: true, Level5: true},
+			want:      LevelShift | LevelThree | LevelFive,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.modifiers.Level())
+		})
+	}
+}
@@ -0,0 +1,354 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Level is a shift level of a key, made up of the level modifiers held down.
+// The base level (no modifiers) is 0, and the levels are numbered as in XKB
+// less one, so for example LevelShift|LevelThree is XKB level 4.
+type Level int
+
+const (
+	// LevelShift is the level chosen by Shift
+	LevelShift Level = 1 << iota
+	// LevelThree is the level chosen by AltGr (ISO_Level3_Shift)
+	LevelThree
+	// LevelFive is the level chosen by ISO_Level5_Shift
+	LevelFive
+)
+
+// maxLevels is the number of shift levels a key can have
+const maxLevels = 8
+
+func (l Level) String() string {
+	if l == 0 {
+		return "Base"
+	}
+	var names []string
+	for _, m := range []struct {
+		level Level
+		name  string
+	}{
+		{LevelShift, "Shift"},
+		{LevelThree, "AltGr"},
+		{LevelFive, "Level5"},
+	} {
+		if l&m.level != 0 {
+			names = append(names, m.name)
+		}
+	}
+	return strings.Join(names, "+")
+}
+
+// Layout maps between the key codes of a keyboard and the characters they
+// produce. It is used to work out the character (AsRune) for each KeyEvent
+// from a KeyboardDevice and the keys to press to type each character on a
+// VirtualKeyboardDevice.
+type Layout interface {
+	// Name returns the name of the layout, for example "us".
+	Name() string
+	// Rune returns the character the key code produces at the given shift
+	// level, or 0 if it produces none.
+	Rune(code int, level Level) rune
+	// Code returns the key code that produces the character and the shift
+	// level it is on, or 0 if no key produces it.
+	Code(r rune) (code int, level Level)
+	// Modifier returns the level modifier (LevelThree or LevelFive) the key
+	// code selects when held down, or 0 if it is not a level modifier. Shift
+	// is always LevelShift, so it is not included.
+	Modifier(code int) Level
+	// DeadKey returns the keysym name of the dead key (for example
+	// "dead_acute") or Compose key ("Multi_key") the key code produces at the
+	// given shift level, or "" if it is neither. These keys produce no
+	// character themselves but start a sequence, see ComposeTable.
+	DeadKey(code int, level Level) string
+}
+
+// levelLayout is a Layout built from a map of key codes to the characters on
+// each of their shift levels, and the dead keys on any levels with none
+type levelLayout struct {
+	name      string
+	keys      map[int][]rune
+	dead      map[int][]string
+	modifiers map[int]Level
+	codes     map[rune]layoutKey
+}
+
+type layoutKey struct {
+	code  int
+	level Level
+}
+
+// newMapLayout will create a layout from a map of key codes to their
+// characters and dead keys, and the key codes of any level modifiers
+func newMapLayout(name string, keys map[int]CharVariants, dead map[int][]string, modifiers map[int]Level) *levelLayout {
+	levels := make(map[int][]rune, len(keys))
+	for code, v := range keys {
+		levels[code] = v.levels()
+	}
+	return newLevelLayout(name, levels, dead, modifiers)
+}
+
+func newLevelLayout(name string, keys map[int][]rune, dead map[int][]string, modifiers map[int]Level) *levelLayout {
+	l := &levelLayout{
+		name:      name,
+		keys:      keys,
+		dead:      dead,
+		modifiers: modifiers,
+		codes:     make(map[rune]layoutKey),
+	}
+	codes := make([]int, 0, len(keys))
+	for code := range keys {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	// Where a character is on more than one key, prefer the lowest level
+	// then the lowest code so the choice is stable.
+	for level := Level(0); level < maxLevels; level++ {
+		for _, code := range codes {
+			if int(level) >= len(keys[code]) {
+				continue
+			}
+			r := keys[code][level]
+			if _, ok := l.codes[r]; ok || r == 0 {
+				continue
+			}
+			l.codes[r] = layoutKey{code: code, level: level}
+		}
+	}
+	return l
+}
+
+func (l *levelLayout) Name() string {
+	return l.name
+}
+
+func (l *levelLayout) Rune(code int, level Level) rune {
+	levels := l.keys[code]
+	i, ok := l.level(code, level)
+	if !ok || i >= len(levels) {
+		return 0
+	}
+	return levels[i]
+}
+
+func (l *levelLayout) DeadKey(code int, level Level) string {
+	dead := l.dead[code]
+	i, ok := l.level(code, level)
+	if !ok || i >= len(dead) {
+		return ""
+	}
+	return dead[i]
+}
+
+// level works out the level of the key to use as XKB would for keys with one,
+// two, four or eight levels: the level modifiers a key has no levels for are
+// ignored. It returns false if the key has no levels.
+func (l *levelLayout) level(code int, level Level) (int, bool) {
+	n := len(l.keys[code])
+	if d := len(l.dead[code]); d > n {
+		n = d
+	}
+	switch {
+	case n == 0:
+		return 0, false
+	case n == 1:
+		level = 0
+	case n == 2:
+		level &= LevelShift
+	case n <= 4:
+		level &= LevelShift | LevelThree
+	}
+	return int(level), true
+}
+
+func (l *levelLayout) Code(r rune) (int, Level) {
+	k := l.codes[r]
+	return k.code, k.level
+}
+
+func (l *levelLayout) Modifier(code int) Level {
+	return l.modifiers[code]
+}
+
+// layouts holds the built-in layouts by name
+var layouts = map[string]Layout{}
+
+func init() {
+	for _, l := range []Layout{
+		newMapLayout("us", runeMap, nil, nil),
+		newMapLayout("gb", gbRuneMap, gbDeadKeys, altGrModifiers),
+		newMapLayout("de", deRuneMap, deDeadKeys, altGrModifiers),
+		newMapLayout("fr", frRuneMap, frDeadKeys, altGrModifiers),
+		newMapLayout("dvorak", dvorakRuneMap, nil, nil),
+		newMapLayout("colemak", colemakRuneMap, nil, nil),
+	} {
+		layouts[l.Name()] = l
+	}
+}
+
+// DefaultLayout returns the layout used when none has been set, the "us"
+// QWERTY layout
+func DefaultLayout() Layout {
+	return layouts["us"]
+}
+
+// LayoutByName will return the built-in layout with the given name, one of
+// those returned by LayoutNames
+func LayoutByName(name string) (Layout, error) {
+	l, ok := layouts[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown layout %q", name)
+	}
+	return l, nil
+}
+
+// LayoutNames returns the names of the built-in layouts
+func LayoutNames() []string {
+	names := make([]string, 0, len(layouts))
+	for name := range layouts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// layoutCodes returns the key codes used by any of the built-in layouts,
+// including their dead keys, the keys a virtual keyboard needs to type with
+// them
+func layoutCodes() []int {
+	seen := make(map[int]bool)
+	var codes []int
+	for _, name := range LayoutNames() {
+		l, ok := layouts[name].(*levelLayout)
+		if !ok {
+			continue
+		}
+		add := func(code int) {
+			if !seen[code] {
+				seen[code] = true
+				codes = append(codes, code)
+			}
+		}
+		for code := range l.keys {
+			add(code)
+		}
+		for code := range l.dead {
+			add(code)
+		}
+	}
+	sort.Ints(codes)
+	return codes
+}
+
+// layoutRune returns the character for the key code with the given modifiers
+// applied. As with XKB, Caps Lock only affects keys with the lower and upper
+// case of a letter on the level chosen, and is cancelled out by Shift.
+func layoutRune(l Layout, code int, modifiers *KeyModifiers) rune {
+	level := modifiers.Level()
+	if modifiers.CapsLock {
+		base := level &^ LevelShift
+		lc, uc := l.Rune(code, base), l.Rune(code, base|LevelShift)
+		if lc != uc && unicode.ToUpper(lc) == uc {
+			level ^= LevelShift
+		}
+	}
+	return l.Rune(code, level)
+}
+
+// setLevelKey will record whether a key the layout uses to choose a shift
+// level is held down, updating the Level3 and Level5 modifiers
+func (k *KeyboardDevice) setLevelKey(layout Layout, code int, down bool) {
+	level := layout.Modifier(code)
+	if level == 0 {
+		return
+	}
+	if k.levelKeys == nil {
+		k.levelKeys = make(map[int]Level)
+	}
+	if down {
+		k.levelKeys[code] = level
+	} else {
+		delete(k.levelKeys, code)
+	}
+	var held Level
+	for _, l := range k.levelKeys {
+		held |= l
+	}
+	k.modifiers.Level3 = held&LevelThree != 0
+	k.modifiers.Level5 = held&LevelFive != 0
+}
+
+// resetLevelKeys will record which of the given keys held down the layout
+// uses to choose a shift level, forgetting any recorded before
+func (k *KeyboardDevice) resetLevelKeys(layout Layout, down []int) {
+	k.levelKeys = nil
+	k.modifiers.Level3 = false
+	k.modifiers.Level5 = false
+	for _, code := range down {
+		if _, ok := lockKeyLEDs[code]; !ok {
+			k.setLevelKey(layout, code, true)
+		}
+	}
+}
+
+// SetLayout sets the layout used to work out the character (AsRune) of each
+// key event from the keyboard. Passing nil restores the default layout. The
+// keys of the new layout that choose a shift level (such as AltGr) are
+// picked up even if they are already held down.
+func (k *KeyboardDevice) SetLayout(l Layout) {
+	k.layoutMu.Lock()
+	defer k.layoutMu.Unlock()
+	k.layout = l
+	k.layoutGen++
+}
+
+// layoutGeneration returns the layout used for the keyboard and how many
+// times it has been set
+func (k *KeyboardDevice) layoutGeneration() (Layout, int) {
+	k.layoutMu.Lock()
+	defer k.layoutMu.Unlock()
+	if k.layout == nil {
+		return DefaultLayout(), k.layoutGen
+	}
+	return k.layout, k.layoutGen
+}
+
+// Layout returns the layout used for the keyboard
+func (k *KeyboardDevice) Layout() Layout {
+	k.layoutMu.Lock()
+	defer k.layoutMu.Unlock()
+	if k.layout == nil {
+		return DefaultLayout()
+	}
+	return k.layout
+}
+
+// SetLayout sets the layout used to work out which keys to press to type
+// each character on the virtual keyboard. This should match the layout the
+// system uses for the virtual keyboard. Passing nil restores the default
+// layout.
+func (u *VirtualKeyboardDevice) SetLayout(l Layout) {
+	u.layoutMu.Lock()
+	defer u.layoutMu.Unlock()
+	u.layout = l
+}
+
+// Layout returns the layout used for the virtual keyboard
+func (u *VirtualKeyboardDevice) Layout() Layout {
+	u.layoutMu.Lock()
+	defer u.layoutMu.Unlock()
+	if u.layout == nil {
+		return DefaultLayout()
+	}
+	return u.layout
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd_test
+
+import (
+	"testing"
+
+	"github.com/joshuar/gokbd"
+	"github.com/joshuar/gokbd/gokbdtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyboardDevice_SetLayout(t *testing.T) {
+	fake := newKeyboard(t)
+	kbd := gokbd.NewKeyboardDevice(fake)
+	assert.Equal(t, "us", kbd.Layout().Name())
+	azerty, err := gokbd.LayoutByName("fr")
+	assert.Nil(t, err)
+	kbd.SetLayout(azerty)
+	keys := snoop(t, kbd).Keys()
+	fake.Tap(int(gokbd.KeyA))
+	assert.Equal(t, 'q', nextKeyPress(t, keys).AsRune)
+}
+
+func TestVirtualKeyboardDevice_SetLayout(t *testing.T) {
+	v, sink := newVirtualKeyboard(t)
+	azerty, err := gokbd.LayoutByName("fr")
+	assert.Nil(t, err)
+	v.SetLayout(azerty)
+	assert.Nil(t, v.TypeString("az"))
+	assert.Equal(t, pressed(gokbd.KeyQ, gokbd.KeyW), sink.Pressed())
+	assert.NotNil(t, v.TypeString("🦍"))
+}
+
+func TestVirtualKeyboardDevice_AltGr(t *testing.T) {
+	v, sink := newVirtualKeyboard(t)
+	qwertz, err := gokbd.LayoutByName("de")
+	assert.Nil(t, err)
+	v.SetLayout(qwertz)
+	assert.Nil(t, v.TypeString("@€0"))
+	assert.Equal(t, pressed(gokbd.KeyRightAlt, gokbd.KeyQ, gokbd.KeyRightAlt, gokbd.KeyE, gokbd.Key0), sink.Pressed())
+
+	// nothing presses Level5 on a virtual keyboard
+	level5, err := gokbd.LoadXKBLayout("testdata/xkb", "xx(level5)")
+	assert.Nil(t, err)
+	v.SetLayout(level5)
+	assert.NotNil(t, v.TypeString("₂"))
+	assert.Nil(t, v.TypeString("²"))
+}
+
+func TestKeyboardDevice_AltGr(t *testing.T) {
+	qwertz, err := gokbd.LayoutByName("de")
+	assert.Nil(t, err)
+	fake := newKeyboard(t)
+	kbd := gokbd.NewKeyboardDevice(fake)
+	kbd.SetLayout(qwertz)
+	keys := snoop(t, kbd).Keys()
+	sink, err := gokbdtest.NewLoopback(fake)
+	assert.Nil(t, err)
+	v := newVirtualKeyboardWithSink(t, sink)
+	v.SetLayout(qwertz)
+	assert.Nil(t, v.TypeString("@Ωz"))
+
+	assert.Equal(t, "KEY_RIGHTALT", nextKeyPress(t, keys).EventName)
+	ev := nextKeyPress(t, keys)
+	assert.Equal(t, '@', ev.AsRune)
+	assert.True(t, ev.Modifiers.Level3)
+	assert.Equal(t, gokbd.LevelThree, ev.Modifiers.Level())
+	assert.Equal(t, "KEY_LEFTSHIFT", nextKeyPress(t, keys).EventName)
+	assert.Equal(t, "KEY_RIGHTALT", nextKeyPress(t, keys).EventName)
+	assert.Equal(t, 'Ω', nextKeyPress(t, keys).AsRune)
+	ev = nextKeyPress(t, keys)
+	assert.Equal(t, 'z', ev.AsRune)
+	assert.False(t, ev.Modifiers.Level3)
+}
+
+func TestKeyboardDevice_HeldAltGr(t *testing.T) {
+	qwertz, err := gokbd.LayoutByName("de")
+	assert.Nil(t, err)
+	fake := newKeyboard(t)
+	// AltGr is already held down when the keyboard is opened
+	fake.Hold(int(gokbd.KeyRightAlt))
+	kbd := gokbd.NewKeyboardDevice(fake)
+	kbd.SetLayout(qwertz)
+	keys := snoop(t, kbd).Keys()
+
+	fake.Tap(int(gokbd.KeyQ))
+	ev := nextKeyPress(t, keys)
+	assert.Equal(t, '@', ev.AsRune)
+	assert.True(t, ev.Modifiers.Level3)
+
+	fake.Release(int(gokbd.KeyRightAlt))
+	fake.Tap(int(gokbd.KeyQ))
+	ev = nextKeyPress(t, keys)
+	assert.Equal(t, 'q', ev.AsRune)
+	assert.False(t, ev.Modifiers.Level3)
+}
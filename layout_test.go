@@ -0,0 +1,243 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLayoutByName(t *testing.T) {
+	tests := []struct {
+		name    string
+		layout  string
+		wantErr bool
+	}{
+		{
+			name:   "default",
+			layout: "us",
+		},
+		{
+			name:   "azerty",
+			layout: "fr",
+		},
+		{
+			name:    "unknown",
+			layout:  "klingon",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LayoutByName(tt.layout)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.layout, got.Name())
+		})
+	}
+	assert.Equal(t, []string{"colemak", "de", "dvorak", "fr", "gb", "us"}, LayoutNames())
+	assert.Equal(t, "us", DefaultLayout().Name())
+}
+
+func Test_mapLayout(t *testing.T) {
+	tests := []struct {
+		layout    string
+		r         rune
+		wantCode  int
+		wantLevel Level
+	}{
+		{layout: "us", r: 'q', wantCode: 16},
+		{layout: "us", r: '@', wantCode: 3, wantLevel: LevelShift},
+		{layout: "gb", r: '@', wantCode: 40, wantLevel: LevelShift},
+		{layout: "gb", r: '£', wantCode: 4, wantLevel: LevelShift},
+		{layout: "de", r: 'z', wantCode: 21},
+		{layout: "de", r: 'Ö', wantCode: 39, wantLevel: LevelShift},
+		{layout: "fr", r: 'a', wantCode: 16},
+		{layout: "fr", r: '1', wantCode: 2, wantLevel: LevelShift},
+		{layout: "fr", r: 'é', wantCode: 3},
+		{layout: "dvorak", r: 'e', wantCode: 32},
+		{layout: "colemak", r: 'T', wantCode: 33, wantLevel: LevelShift},
+		{layout: "de", r: '@', wantCode: 16, wantLevel: LevelThree},
+		{layout: "de", r: 'Ω', wantCode: 16, wantLevel: LevelShift | LevelThree},
+		{layout: "fr", r: '€', wantCode: 18, wantLevel: LevelThree},
+		{layout: "gb", r: '€', wantCode: 5, wantLevel: LevelThree},
+		{layout: "us", r: '€'},
+	}
+	for _, tt := range tests {
+		t.Run(tt.layout+" "+string(tt.r), func(t *testing.T) {
+			l, err := LayoutByName(tt.layout)
+			assert.Nil(t, err)
+			code, level := l.Code(tt.r)
+			assert.Equal(t, tt.wantCode, code)
+			assert.Equal(t, tt.wantLevel, level)
+			if code != 0 {
+				assert.Equal(t, tt.r, l.Rune(code, level))
+			}
+		})
+	}
+}
+
+func Test_mapLayout_roundTrip(t *testing.T) {
+	for _, name := range LayoutNames() {
+		l, err := LayoutByName(name)
+		assert.Nil(t, err)
+		for _, code := range layoutCodes() {
+			for level := Level(0); level < maxLevels; level++ {
+				r := l.Rune(code, level)
+				if r == 0 {
+					continue
+				}
+				gotCode, gotLevel := l.Code(r)
+				assert.Equal(t, r, l.Rune(gotCode, gotLevel), "%s: %q", name, r)
+				assert.LessOrEqual(t, gotLevel, level, "%s: %q", name, r)
+			}
+		}
+	}
+}
+
+func Test_layoutRune(t *testing.T) {
+	fr, err := LayoutByName("fr")
+	assert.Nil(t, err)
+	tests := []struct {
+		name      string
+		layout    Layout
+		code      int
+		modifiers *KeyModifiers
+		want      rune
+	}{
+		{
+			name:      "shift",
+			layout:    DefaultLayout(),
+			code:      30,
+			modifiers: &KeyModifiers{Shift: true},
+			want:      'A',
+		},
+		{
+			name:      "capslock letter",
+			layout:    DefaultLayout(),
+			code:      30,
+			modifiers: &KeyModifiers{CapsLock: true},
+			want:      'A',
+		},
+		{
+			name:      "capslock and shift",
+			layout:    DefaultLayout(),
+			code:      30,
+			modifiers: &KeyModifiers{CapsLock: true, Shift: true},
+			want:      'a',
+		},
+		{
+			name:      "capslock digit",
+			layout:    DefaultLayout(),
+			code:      2,
+			modifiers: &KeyModifiers{CapsLock: true},
+			want:      '1',
+		},
+		{
+			name:      "azerty capslock accented digit key",
+			layout:    fr,
+			code:      3,
+			modifiers: &KeyModifiers{CapsLock: true},
+			want:      'é',
+		},
+		{
+			name:      "altgr",
+			layout:    fr,
+			code:      18,
+			modifiers: &KeyModifiers{Level3: true},
+			want:      '€',
+		},
+		{
+			name:      "capslock altgr letter",
+			layout:    fr,
+			code:      16,
+			modifiers: &KeyModifiers{Level3: true, CapsLock: true},
+			want:      'Æ',
+		},
+		{
+			name:      "azerty capslock symbol",
+			layout:    fr,
+			code:      2,
+			modifiers: &KeyModifiers{CapsLock: true},
+			want:      '&',
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, layoutRune(tt.layout, tt.code, tt.modifiers))
+		})
+	}
+}
+
+func TestLevel_String(t *testing.T) {
+	assert.Equal(t, "Base", Level(0).String())
+	assert.Equal(t, "Shift", LevelShift.String())
+	assert.Equal(t, "Shift+AltGr", (LevelShift | LevelThree).String())
+	assert.Equal(t, "AltGr+Level5", (LevelThree | LevelFive).String())
+}
+
+func Test_levelLayout_Rune(t *testing.T) {
+	l := newLevelLayout("test", map[int][]rune{
+		1: {' '},
+		2: {'a', 'A'},
+		3: {'b', 'B', 'x'},
+		4: {'c', 'C', 'y', 'Y', 'z', 'Z', 0, '!'},
+	}, nil, nil)
+	tests := []struct {
+		name  string
+		code  int
+		level Level
+		want  rune
+	}{
+		{name: "one level ignores shift", code: 1, level: LevelShift, want: ' '},
+		{name: "two levels ignore altgr", code: 2, level: LevelShift | LevelThree, want: 'A'},
+		{name: "missing fourth level", code: 3, level: LevelShift | LevelThree},
+		{name: "four levels ignore level5", code: 3, level: LevelThree | LevelFive, want: 'x'},
+		{name: "eight levels", code: 4, level: LevelShift | LevelFive, want: 'Z'},
+		{name: "no symbol", code: 4, level: LevelThree | LevelFive},
+		{name: "all modifiers", code: 4, level: LevelShift | LevelThree | LevelFive, want: '!'},
+		{name: "no key", code: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, l.Rune(tt.code, tt.level))
+		})
+	}
+}
+
+func Test_levelLayout_DeadKey(t *testing.T) {
+	l := newLevelLayout("test", map[int][]rune{
+		1: {'a', 'A'},
+		2: {'b', 'B'},
+	}, map[int][]string{
+		2: {"", "", "dead_acute", "dead_grave"},
+		3: {"dead_circumflex"},
+	}, nil)
+	tests := []struct {
+		name     string
+		code     int
+		level    Level
+		want     string
+		wantRune rune
+	}{
+		{name: "no dead keys", code: 1, level: LevelThree, wantRune: 'a'},
+		{name: "character level", code: 2, level: LevelShift, wantRune: 'B'},
+		{name: "dead key level", code: 2, level: LevelThree, want: "dead_acute"},
+		{name: "dead key shifted", code: 2, level: LevelShift | LevelThree, want: "dead_grave"},
+		{name: "one level ignores shift", code: 3, level: LevelShift, want: "dead_circumflex"},
+		{name: "no key", code: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, l.DeadKey(tt.code, tt.level))
+			assert.Equal(t, tt.wantRune, l.Rune(tt.code, tt.level))
+		})
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sys/unix"
+)
+
+// LED represents one of the lock indicator LEDs on a keyboard
+type LED int
+
+const (
+	LEDNumLock    LED = ledNumL
+	LEDCapsLock   LED = ledCapsL
+	LEDScrollLock LED = ledScrollL
+	LEDCompose    LED = ledCompose
+	LEDKana       LED = ledKana
+)
+
+// keyboardLEDs are the LEDs exposed by a virtual keyboard
+var keyboardLEDs = []int{ledNumL, ledCapsL, ledScrollL, ledCompose, ledKana}
+
+func (l LED) String() string {
+	switch l {
+	case LEDNumLock:
+		return "NumLock"
+	case LEDCapsLock:
+		return "CapsLock"
+	case LEDScrollLock:
+		return "ScrollLock"
+	case LEDCompose:
+		return "Compose"
+	case LEDKana:
+		return "Kana"
+	}
+	if name := eventCodeName(evLed, uint16(l)); name != "" {
+		return name
+	}
+	return fmt.Sprintf("LED(%d)", int(l))
+}
+
+// LEDEvent represents an LED on a virtual keyboard being turned on or off by
+// the kernel, for example when Caps Lock is pressed on another keyboard
+type LEDEvent struct {
+	LED LED
+	On  bool
+}
+
+func toLEDs(codes []int) []LED {
+	leds := make([]LED, 0, len(codes))
+	for _, code := range codes {
+		leds = append(leds, LED(code))
+	}
+	return leds
+}
+
+// ledChangesSize is how many LED changes are buffered for a virtual keyboard
+const ledChangesSize = 16
+
+// SetLED will turn the given LED on the keyboard on or off. This needs write
+// access to the keyboard device.
+func (k *KeyboardDevice) SetLED(led LED, on bool) error {
+	if err := k.src.SetLED(int(led), on); err != nil {
+		return fmt.Errorf("could not set %s LED: %w", led, err)
+	}
+	return nil
+}
+
+// GetLEDs will return the LEDs currently lit on the keyboard
+func (k *KeyboardDevice) GetLEDs() ([]LED, error) {
+	on, err := k.src.LEDsOn()
+	if err != nil {
+		return nil, fmt.Errorf("could not get LEDs: %w", err)
+	}
+	return toLEDs(on), nil
+}
+
+// GetLEDs will return the LEDs the kernel has lit on the virtual keyboard
+func (u *VirtualKeyboardDevice) GetLEDs() []LED {
+	u.ledMu.Lock()
+	defer u.ledMu.Unlock()
+	var leds []LED
+	for _, code := range keyboardLEDs {
+		if u.leds[LED(code)] {
+			leds = append(leds, LED(code))
+		}
+	}
+	return leds
+}
+
+// LEDChanges will return a channel that receives an LEDEvent whenever the
+// kernel turns an LED on the virtual keyboard on or off. Changes are dropped
+// if the channel is not read from quickly enough. The channel is closed when
+// the virtual keyboard is closed.
+func (u *VirtualKeyboardDevice) LEDChanges() <-chan LEDEvent {
+	return u.ledChanges
+}
+
+// watchLEDs will read the LED changes written back to the virtual keyboard
+// until it is closed
+func (u *VirtualKeyboardDevice) watchLEDs() {
+	defer u.ledWG.Done()
+	defer close(u.ledChanges)
+	fds := []unix.PollFd{
+		{Fd: int32(u.sink.Fd()), Events: unix.POLLIN},
+		{Fd: int32(u.wakefd), Events: unix.POLLIN},
+	}
+	for {
+		if _, err := unix.Poll(fds, -1); err != nil {
+			if errors.Is(err, unix.EINTR) {
+				continue
+			}
+			log.Error().Caller().Err(err).
+				Msg("Could not watch LEDs.")
+			return
+		}
+		if fds[1].Revents != 0 {
+			return
+		}
+		if fds[0].Revents&(unix.POLLERR|unix.POLLHUP|unix.POLLNVAL) != 0 {
+			log.Debug().Caller().
+				Msgf("Stopped watching LEDs on %s.", u.Name)
+			return
+		}
+		for {
+			ev, err := u.sink.NextEvent()
+			if errors.Is(err, ErrNoEvents) {
+				break
+			}
+			if err != nil {
+				log.Error().Caller().Err(err).
+					Msg("Could not read LED change.")
+				return
+			}
+			if ev.Type != evLed {
+				continue
+			}
+			change := LEDEvent{LED: LED(ev.Code), On: ev.Value != 0}
+			u.ledMu.Lock()
+			u.leds[change.LED] = change.On
+			u.ledMu.Unlock()
+			select {
+			case u.ledChanges <- change:
+			default:
+				log.Debug().Caller().
+					Msgf("Dropped %s LED change on %s.", change.LED, u.Name)
+			}
+		}
+	}
+}
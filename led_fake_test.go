@@ -0,0 +1,43 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/joshuar/gokbd"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyboardDevice_SetLED(t *testing.T) {
+	kbd := gokbd.NewKeyboardDevice(newKeyboard(t))
+	assert.Nil(t, kbd.SetLED(gokbd.LEDScrollLock, true))
+	leds, err := kbd.GetLEDs()
+	assert.Nil(t, err)
+	assert.Equal(t, []gokbd.LED{gokbd.LEDScrollLock}, leds)
+	assert.Nil(t, kbd.SetLED(gokbd.LEDScrollLock, false))
+	leds, err = kbd.GetLEDs()
+	assert.Nil(t, err)
+	assert.Empty(t, leds)
+	kbd.Close()
+	assert.NotNil(t, kbd.SetLED(gokbd.LEDScrollLock, true))
+}
+
+func TestVirtualKeyboardDevice_LEDChanges(t *testing.T) {
+	v, sink := newVirtualKeyboard(t)
+	sink.SetLED(int(gokbd.LEDCapsLock), true)
+	select {
+	case ev := <-v.LEDChanges():
+		assert.Equal(t, gokbd.LEDEvent{LED: gokbd.LEDCapsLock, On: true}, ev)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for LED change")
+	}
+	assert.Equal(t, []gokbd.LED{gokbd.LEDCapsLock}, v.GetLEDs())
+	v.Close()
+	_, ok := <-v.LEDChanges()
+	assert.False(t, ok)
+}
@@ -0,0 +1,51 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLED_String(t *testing.T) {
+	tests := []struct {
+		name string
+		l    LED
+		want string
+	}{
+		{
+			name: "caps lock",
+			l:    LEDCapsLock,
+			want: "CapsLock",
+		},
+		{
+			name: "kana",
+			l:    LEDKana,
+			want: "Kana",
+		},
+		{
+			name: "other known LED",
+			l:    LED(0x07),
+			want: "LED_MUTE",
+		},
+		{
+			name: "unknown",
+			l:    LED(0x0e),
+			want: "LED(14)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.l.String())
+		})
+	}
+}
+
+func Test_toLEDs(t *testing.T) {
+	assert.Equal(t, []LED{LEDNumLock, LEDCapsLock}, toLEDs([]int{ledNumL, ledCapsL}))
+	assert.Empty(t, toLEDs(nil))
+}
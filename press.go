@@ -0,0 +1,203 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Press will press the key and leave it held down until it is released with
+// Release or ReleaseAll, or the virtual keyboard is closed. The key must be
+// enabled on the virtual keyboard and not already held. While a key is held,
+// the other ways of typing leave it held rather than pressing or releasing it
+// again. To also release the key when a context is cancelled, use
+// PressContext.
+func (u *VirtualKeyboardDevice) Press(k Key) error {
+	_, err := u.press(k)
+	return err
+}
+
+// PressContext will press the key, as Press, and release it once the context
+// is cancelled, unless it has already been released. Nothing is pressed if the
+// context is already cancelled.
+func (u *VirtualKeyboardDevice) PressContext(ctx context.Context, k Key) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	released, err := u.press(k)
+	if err != nil {
+		return err
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			u.heldMu.Lock()
+			defer u.heldMu.Unlock()
+			// The key may have been released, and pressed again, in the
+			// meantime.
+			if u.releases[k] != released {
+				return
+			}
+			if err := u.release(k); err != nil {
+				log.Debug().Caller().Err(err).
+					Msgf("Could not release key %s.", k)
+			}
+		case <-released:
+		}
+	}()
+	return nil
+}
+
+// press will press the key, as Press, and return a channel that is closed
+// when it is released
+func (u *VirtualKeyboardDevice) press(k Key) (chan struct{}, error) {
+	if !u.keys[k] {
+		return nil, fmt.Errorf("key %s is not enabled on the virtual keyboard", k)
+	}
+	u.heldMu.Lock()
+	defer u.heldMu.Unlock()
+	if indexKey(u.held, k) >= 0 {
+		return nil, fmt.Errorf("key %s is already pressed", k)
+	}
+	press := []InputEvent{{Type: evKey, Code: uint16(k), Value: 1}, {Type: evSyn, Code: synReport}}
+	if err := u.writeEvents(press); err != nil {
+		return nil, errors.Join(err, u.writeEvents(releaseEvents([]Key{k})))
+	}
+	if u.releases == nil {
+		u.releases = make(map[Key]chan struct{})
+	}
+	released := make(chan struct{})
+	u.releases[k] = released
+	u.held = append(u.held, k)
+	return released, nil
+}
+
+// Release will release a key held down with Press
+func (u *VirtualKeyboardDevice) Release(k Key) error {
+	u.heldMu.Lock()
+	defer u.heldMu.Unlock()
+	return u.release(k)
+}
+
+// release will release a key held down with Press. heldMu must be held.
+func (u *VirtualKeyboardDevice) release(k Key) error {
+	i := indexKey(u.held, k)
+	if i < 0 {
+		return fmt.Errorf("key %s is not pressed", k)
+	}
+	u.held = append(u.held[:i], u.held[i+1:]...)
+	close(u.releases[k])
+	delete(u.releases, k)
+	return u.writeEvents(releaseEvents([]Key{k}))
+}
+
+// Hold will press the key, wait for the duration and then release it. If the
+// context is cancelled first, the key is released straight away and the
+// context's error returned.
+func (u *VirtualKeyboardDevice) Hold(ctx context.Context, k Key, d time.Duration) error {
+	if err := u.Press(k); err != nil {
+		return err
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	var err error
+	select {
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timer.C:
+	}
+	return errors.Join(err, u.Release(k))
+}
+
+// ReleaseAll will release every key held down with Press, in the reverse of
+// the order they were pressed. A release is attempted for every key, even if
+// an earlier one fails.
+func (u *VirtualKeyboardDevice) ReleaseAll() error {
+	u.heldMu.Lock()
+	defer u.heldMu.Unlock()
+	var errs []error
+	for i := len(u.held) - 1; i >= 0; i-- {
+		if err := u.writeEvents(releaseEvents(u.held[i : i+1])); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, released := range u.releases {
+		close(released)
+	}
+	u.held = nil
+	u.releases = nil
+	return errors.Join(errs...)
+}
+
+// Held returns the keys currently held down with Press, in the order they
+// were pressed
+func (u *VirtualKeyboardDevice) Held() []Key {
+	u.heldMu.Lock()
+	defer u.heldMu.Unlock()
+	return append([]Key(nil), u.held...)
+}
+
+// withoutHeld returns the key events without the presses and releases of the
+// held keys (and the SYN_REPORT that follows each), so typing does not release
+// them
+func withoutHeld(held []Key, keys []*key) []*key {
+	if len(held) == 0 {
+		return keys
+	}
+	filtered := make([]*key, 0, len(keys))
+	skipSync := false
+	for _, k := range keys {
+		switch {
+		case k.keyType == evKey && indexKey(held, Key(k.keyCode)) >= 0:
+			skipSync = true
+			continue
+		case skipSync && k.keyType == evSyn:
+			skipSync = false
+			continue
+		}
+		skipSync = false
+		filtered = append(filtered, k)
+	}
+	return filtered
+}
+
+// withoutHeldEvents returns the events without the presses and releases of
+// the held keys (and the SYN_REPORT that follows each), as withoutHeld
+func withoutHeldEvents(held []Key, events []InputEvent) []InputEvent {
+	if len(held) == 0 {
+		return events
+	}
+	filtered := make([]InputEvent, 0, len(events))
+	skipSync := false
+	for _, ev := range events {
+		switch {
+		case ev.Type == evKey && indexKey(held, Key(ev.Code)) >= 0:
+			skipSync = true
+			continue
+		case skipSync && ev.Type == evSyn:
+			skipSync = false
+			continue
+		}
+		skipSync = false
+		filtered = append(filtered, ev)
+	}
+	return filtered
+}
+
+// indexKey returns the index of the key, or -1 if it is not in the keys
+func indexKey(keys []Key, k Key) int {
+	for i, h := range keys {
+		if h == k {
+			return i
+		}
+	}
+	return -1
+}
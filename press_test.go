@@ -0,0 +1,117 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/joshuar/gokbd"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVirtualKeyboardDevice_Press(t *testing.T) {
+	v, sink := newVirtualKeyboard(t)
+
+	assert.Nil(t, v.Press(gokbd.KeyLeftShift))
+	assert.NotNil(t, v.Press(gokbd.KeyLeftShift))
+	assert.NotNil(t, v.Press(gokbd.KeyF5))
+	// Typing leaves the held Shift alone.
+	assert.Nil(t, v.TypeRune('A'))
+	assert.Nil(t, v.TypeChord(gokbd.KeyLeftShift, gokbd.KeyLeftCtrl, gokbd.KeyA))
+	assert.Nil(t, v.Press(gokbd.KeyLeftCtrl))
+	assert.Equal(t, []gokbd.Key{gokbd.KeyLeftShift, gokbd.KeyLeftCtrl}, v.Held())
+	assert.Equal(t, []int{int(gokbd.KeyLeftShift), int(gokbd.KeyA), int(gokbd.KeyLeftCtrl), int(gokbd.KeyA), int(gokbd.KeyLeftCtrl)}, sink.Pressed())
+
+	sink.Reset()
+	assert.Nil(t, v.Release(gokbd.KeyLeftShift))
+	assert.NotNil(t, v.Release(gokbd.KeyLeftShift))
+	assert.Equal(t, []gokbd.Key{gokbd.KeyLeftCtrl}, v.Held())
+	assert.Equal(t, []gokbd.InputEvent{keyEvent(gokbd.KeyLeftShift, 0), syncEvent()}, keyEvents(sink))
+
+	// Close releases any keys still held, in reverse order.
+	sink.Reset()
+	assert.Nil(t, v.Press(gokbd.KeyLeftAlt))
+	v.Close()
+	assert.Equal(t, []gokbd.InputEvent{
+		keyEvent(gokbd.KeyLeftAlt, 1), syncEvent(),
+		keyEvent(gokbd.KeyLeftAlt, 0), syncEvent(),
+		keyEvent(gokbd.KeyLeftCtrl, 0), syncEvent(),
+	}, keyEvents(sink))
+	assert.Empty(t, v.Held())
+
+	// Closing again writes nothing more.
+	writes := sink.Writes()
+	v.Close()
+	assert.Equal(t, writes, sink.Writes())
+}
+
+func TestVirtualKeyboardDevice_PressContext(t *testing.T) {
+	v, sink := newVirtualKeyboard(t)
+
+	// cancelling the context releases the key
+	ctx, cancel := context.WithCancel(context.Background())
+	assert.Nil(t, v.PressContext(ctx, gokbd.KeyLeftShift))
+	assert.Equal(t, []gokbd.Key{gokbd.KeyLeftShift}, v.Held())
+	cancel()
+	assert.Eventually(t, func() bool { return len(v.Held()) == 0 }, time.Second, time.Millisecond)
+	assert.Equal(t, []gokbd.InputEvent{
+		keyEvent(gokbd.KeyLeftShift, 1), syncEvent(),
+		keyEvent(gokbd.KeyLeftShift, 0), syncEvent(),
+	}, keyEvents(sink))
+	assert.ErrorIs(t, v.PressContext(ctx, gokbd.KeyLeftShift), context.Canceled)
+
+	// a key released and pressed again is not released by the first context
+	ctx, cancel = context.WithCancel(context.Background())
+	assert.Nil(t, v.PressContext(ctx, gokbd.KeyLeftShift))
+	assert.Nil(t, v.Release(gokbd.KeyLeftShift))
+	assert.Nil(t, v.Press(gokbd.KeyLeftShift))
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, []gokbd.Key{gokbd.KeyLeftShift}, v.Held())
+}
+
+func TestVirtualKeyboardDevice_Hold(t *testing.T) {
+	v, sink := newVirtualKeyboard(t)
+
+	assert.Nil(t, v.Hold(context.Background(), gokbd.KeyA, 20*time.Millisecond))
+	events := sink.Events()
+	if assert.Len(t, events, 4) {
+		assert.GreaterOrEqual(t, events[2].Time.Sub(events[0].Time), 20*time.Millisecond)
+	}
+
+	sink.Reset()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := v.Hold(ctx, gokbd.KeyA, time.Minute)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, []gokbd.InputEvent{
+		keyEvent(gokbd.KeyA, 1), syncEvent(),
+		keyEvent(gokbd.KeyA, 0), syncEvent(),
+	}, keyEvents(sink))
+	assert.Empty(t, v.Held())
+}
+
+func TestVirtualKeyboardDevice_HoldWhileTyping(t *testing.T) {
+	v, _ := newVirtualKeyboard(t,
+		gokbd.WithTypingProfile(gokbd.TypingProfile{Dwell: 500 * time.Millisecond}))
+
+	// a key held while another is typed is released on time, not once the
+	// typing has finished
+	typed := make(chan error)
+	go func() {
+		typed <- v.TypeChord(gokbd.KeyLeftCtrl, gokbd.KeyA)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	start := time.Now()
+	assert.Nil(t, v.Hold(context.Background(), gokbd.KeyLeftShift, 10*time.Millisecond))
+	assert.Less(t, time.Since(start), 250*time.Millisecond)
+	assert.Empty(t, v.Held())
+	assert.Nil(t, <-typed)
+}
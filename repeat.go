@@ -0,0 +1,182 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// The autorepeat delay and period the kernel uses when a device does not set
+// its own, and that are used to synthesise repeats if a device reports none.
+const (
+	defaultRepeatDelay  = 250 * time.Millisecond
+	defaultRepeatPeriod = 33 * time.Millisecond
+)
+
+// RepeatMode controls how a Snooper handles autorepeat (key events with a
+// Value of 2)
+type RepeatMode int
+
+const (
+	// RepeatPassthrough passes out repeat events exactly as the keyboard
+	// reports them. This is the default.
+	RepeatPassthrough RepeatMode = iota
+	// RepeatSuppress drops all repeat events, so only key presses and
+	// releases are passed out.
+	RepeatSuppress
+	// RepeatSynthesise drops any repeat events from the keyboard and instead
+	// generates them while a key is held down, using the keyboard's repeat
+	// delay and period. This gives consistent repeats from keyboards that
+	// repeat in hardware, in the kernel or not at all.
+	RepeatSynthesise
+)
+
+func (m RepeatMode) String() string {
+	switch m {
+	case RepeatPassthrough:
+		return "RepeatPassthrough"
+	case RepeatSuppress:
+		return "RepeatSuppress"
+	case RepeatSynthesise:
+		return "RepeatSynthesise"
+	default:
+		return fmt.Sprintf("RepeatMode(%d)", int(m))
+	}
+}
+
+// GetRepeat will return the delay before a held key starts repeating and the
+// period between repeats for the keyboard
+func (k *KeyboardDevice) GetRepeat() (time.Duration, time.Duration, error) {
+	delay, period, err := k.src.Repeat()
+	if err != nil {
+		return 0, 0, fmt.Errorf("could not get repeat rate: %w", err)
+	}
+	return time.Duration(delay) * time.Millisecond, time.Duration(period) * time.Millisecond, nil
+}
+
+// SetRepeat will set the delay before a held key starts repeating and the
+// period between repeats for the keyboard. The values are rounded down to the
+// nearest millisecond.
+func (k *KeyboardDevice) SetRepeat(delay, period time.Duration) error {
+	if err := k.src.SetRepeat(int(delay.Milliseconds()), int(period.Milliseconds())); err != nil {
+		return fmt.Errorf("could not set repeat rate: %w", err)
+	}
+	return nil
+}
+
+// GetRepeat will return the delay before a held key starts repeating and the
+// period between repeats for the virtual keyboard
+func (u *VirtualKeyboardDevice) GetRepeat() (time.Duration, time.Duration, error) {
+	delay, period, err := u.sink.Repeat()
+	if err != nil {
+		return 0, 0, fmt.Errorf("could not get repeat rate: %w", err)
+	}
+	return time.Duration(delay) * time.Millisecond, time.Duration(period) * time.Millisecond, nil
+}
+
+// SetRepeat will set the delay before a held key starts repeating and the
+// period between repeats for the virtual keyboard. The values are rounded
+// down to the nearest millisecond.
+func (u *VirtualKeyboardDevice) SetRepeat(delay, period time.Duration) error {
+	if err := u.sink.SetRepeat(int(delay.Milliseconds()), int(period.Milliseconds())); err != nil {
+		return fmt.Errorf("could not set repeat rate: %w", err)
+	}
+	return nil
+}
+
+// keyRepeat tracks the key held down on a keyboard being snooped, so repeats
+// can be dropped or synthesised
+type keyRepeat struct {
+	held   *KeyEvent
+	next   time.Time
+	delay  time.Duration
+	period time.Duration
+	// dropped and passed record whether any event of the current frame (up
+	// to the next SYN_REPORT) was dropped or passed out
+	dropped bool
+	passed  bool
+}
+
+func newKeyRepeat(kbd *KeyboardDevice) *keyRepeat {
+	delay, period, err := kbd.GetRepeat()
+	if err != nil || delay <= 0 || period <= 0 {
+		log.Debug().Caller().Err(err).
+			Msgf("Using default repeat rate for device %s.", kbd.DevNode)
+		delay, period = defaultRepeatDelay, defaultRepeatPeriod
+	}
+	return &keyRepeat{
+		delay:  delay,
+		period: period,
+	}
+}
+
+// filter will return whether the event should be passed out with the given
+// mode, tracking which key is held down when synthesising repeats. The
+// SYN_REPORT ending a frame of only dropped repeats is dropped too.
+func (r *keyRepeat) filter(mode RepeatMode, e KeyEvent, now time.Time) bool {
+	switch {
+	case e.eventRaw.Type == evSyn && e.eventRaw.Code == synReport:
+		pass := r.passed || !r.dropped
+		r.dropped, r.passed = false, false
+		return pass
+	case e.eventRaw.Type != evKey:
+	case e.Value == 2:
+		if mode != RepeatPassthrough {
+			r.dropped = true
+			return false
+		}
+	case mode != RepeatSynthesise:
+	case e.Value == 1:
+		// As with the kernel, only the most recently pressed key repeats.
+		r.held = &e
+		r.next = now.Add(r.delay)
+	case r.held != nil && r.held.eventRaw.Code == e.eventRaw.Code:
+		r.held = nil
+	}
+	r.passed = true
+	return true
+}
+
+// due will return how long until the next repeat is due, or false if no key
+// is held down
+func (r *keyRepeat) due(now time.Time) (time.Duration, bool) {
+	if r.held == nil {
+		return 0, false
+	}
+	if d := r.next.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
+
+// fire will pass out a repeat of the held key (followed by a SYN_REPORT) if
+// one is due, with the keyboard's current modifiers. Repeats missed while the receiver was busy are skipped rather
+// than sent in a burst. It returns false if emit did.
+func (r *keyRepeat) fire(now time.Time, emit func(KeyEvent) bool) bool {
+	if r.held != nil && !now.Before(r.next) {
+		rep := *r.held
+		rep.Time = now
+		rep.Value = 2
+		rep.eventRaw.Time = now
+		rep.eventRaw.Value = 2
+		if kbd := rep.Device; kbd != nil {
+			// The modifiers may have changed since the key was pressed.
+			rep.Modifiers = *kbd.modifiers
+			rep.updateRune(kbd.Layout(), kbd.modifiers)
+		}
+		syn := NewKeyEvent(InputEvent{Time: now, Type: evSyn, Code: synReport})
+		syn.Device = rep.Device
+		syn.Modifiers = rep.Modifiers
+		if !emit(rep) || !emit(*syn) {
+			return false
+		}
+		r.next = now.Add(r.period)
+	}
+	return true
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joshuar/gokbd"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyboardDevice_Repeat(t *testing.T) {
+	kbd := gokbd.NewKeyboardDevice(newKeyboard(t))
+	delay, period, err := kbd.GetRepeat()
+	assert.Nil(t, err)
+	assert.Equal(t, 250*time.Millisecond, delay)
+	assert.Equal(t, 33*time.Millisecond, period)
+	assert.Nil(t, kbd.SetRepeat(500*time.Millisecond, 20*time.Millisecond))
+	delay, period, err = kbd.GetRepeat()
+	assert.Nil(t, err)
+	assert.Equal(t, 500*time.Millisecond, delay)
+	assert.Equal(t, 20*time.Millisecond, period)
+}
+
+func TestSnooper_RepeatModes(t *testing.T) {
+	tests := []struct {
+		name        string
+		mode        gokbd.RepeatMode
+		wait        time.Duration
+		wantRepeats bool
+	}{
+		{
+			name:        "passthrough",
+			mode:        gokbd.RepeatPassthrough,
+			wantRepeats: true,
+		},
+		{
+			name: "suppress",
+			mode: gokbd.RepeatSuppress,
+			wait: 100 * time.Millisecond,
+		},
+		{
+			name:        "synthesise",
+			mode:        gokbd.RepeatSynthesise,
+			wait:        100 * time.Millisecond,
+			wantRepeats: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newKeyboard(t)
+			assert.Nil(t, fake.SetRepeat(20, 10))
+			s := snoop(t, gokbd.NewKeyboardDevice(fake), gokbd.WithRepeatMode(tt.mode))
+
+			repeats := make(chan int)
+			go func() {
+				var n int
+				for ev := range s.Keys() {
+					if ev.Value == 2 {
+						n++
+					}
+					if ev.IsKeyRelease() {
+						break
+					}
+				}
+				repeats <- n
+			}()
+
+			// the device's own repeat is only passed through when asked
+			fake.Press(int(gokbd.KeyA))
+			fake.Push(keyEvent(gokbd.KeyA, 2), syncEvent())
+			time.Sleep(tt.wait)
+			fake.Release(int(gokbd.KeyA))
+			n := <-repeats
+			assert.Equal(t, tt.wantRepeats, n > 0)
+			if tt.mode == gokbd.RepeatSynthesise {
+				// roughly 100ms of holding, 20ms before the first repeat
+				// then every 10ms
+				assert.Greater(t, n, 2)
+			}
+		})
+	}
+}
+
+func TestSnooper_ComposeRepeat(t *testing.T) {
+	table, err := gokbd.ParseCompose(strings.NewReader(`<Multi_key> <o> <c>	: "©"	copyright`))
+	assert.Nil(t, err)
+	fake := newKeyboard(t)
+	assert.Nil(t, fake.SetRepeat(20, 10))
+	s := snoop(t, gokbd.NewKeyboardDevice(fake),
+		gokbd.WithCompose(table), gokbd.WithRepeatMode(gokbd.RepeatSynthesise))
+
+	repeats := make(chan []rune)
+	go func() {
+		var runes []rune
+		for ev := range s.Keys() {
+			if ev.Value == 2 {
+				runes = append(runes, ev.AsRune)
+			}
+			if ev.IsKeyRelease() && ev.Code == gokbd.KeyO {
+				break
+			}
+		}
+		repeats <- runes
+	}()
+
+	// the synthesised repeats of a key held part way through a sequence
+	// have no rune, as the keyboard's own repeats would
+	fake.Tap(int(gokbd.KeyCompose))
+	fake.Press(int(gokbd.KeyO))
+	time.Sleep(100 * time.Millisecond)
+	fake.Release(int(gokbd.KeyO))
+	runes := <-repeats
+	assert.NotEmpty(t, runes)
+	for _, r := range runes {
+		assert.Zero(t, r)
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepeatMode_String(t *testing.T) {
+	assert.Equal(t, "RepeatSuppress", RepeatSuppress.String())
+	assert.Equal(t, "RepeatMode(7)", RepeatMode(7).String())
+}
+
+func Test_keyRepeat_filter(t *testing.T) {
+	press := *NewKeyEvent(InputEvent{Type: evKey, Code: 30, Value: 1})
+	repeat := *NewKeyEvent(InputEvent{Type: evKey, Code: 30, Value: 2})
+	release := *NewKeyEvent(InputEvent{Type: evKey, Code: 30, Value: 0})
+	otherRelease := *NewKeyEvent(InputEvent{Type: evKey, Code: 31, Value: 0})
+	scan := *NewKeyEvent(InputEvent{Type: uint16(EventMsc), Code: 4, Value: 0x70004})
+	syn := *NewKeyEvent(InputEvent{Type: evSyn, Code: synReport})
+	tests := []struct {
+		name     string
+		mode     RepeatMode
+		events   []KeyEvent
+		want     []bool
+		wantHeld bool
+	}{
+		{
+			name:   "passthrough",
+			mode:   RepeatPassthrough,
+			events: []KeyEvent{press, syn, repeat, syn},
+			want:   []bool{true, true, true, true},
+		},
+		{
+			name:   "suppress",
+			mode:   RepeatSuppress,
+			events: []KeyEvent{press, syn, repeat, syn},
+			want:   []bool{true, true, false, false},
+		},
+		{
+			name:   "suppress keeps frame with other events",
+			mode:   RepeatSuppress,
+			events: []KeyEvent{scan, repeat, syn, press, syn},
+			want:   []bool{true, false, true, true, true},
+		},
+		{
+			name:     "synthesise holds key",
+			mode:     RepeatSynthesise,
+			events:   []KeyEvent{press, syn, repeat, syn, otherRelease, syn},
+			want:     []bool{true, true, false, false, true, true},
+			wantHeld: true,
+		},
+		{
+			name:   "synthesise releases key",
+			mode:   RepeatSynthesise,
+			events: []KeyEvent{press, release},
+			want:   []bool{true, true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &keyRepeat{delay: time.Second, period: time.Second}
+			var got []bool
+			for _, e := range tt.events {
+				got = append(got, r.filter(tt.mode, e, time.Now()))
+			}
+			assert.Equal(t, tt.want, got)
+			_, held := r.due(time.Now())
+			assert.Equal(t, tt.wantHeld, held)
+		})
+	}
+}
+
+func Test_keyRepeat_fire(t *testing.T) {
+	start := time.Now()
+	r := &keyRepeat{delay: 250 * time.Millisecond, period: 30 * time.Millisecond}
+	r.filter(RepeatSynthesise, *NewKeyEvent(InputEvent{Type: evKey, Code: 30, Value: 1}), start)
+	var got []KeyEvent
+	emit := func(e KeyEvent) bool {
+		got = append(got, e)
+		return true
+	}
+
+	d, ok := r.due(start)
+	assert.True(t, ok)
+	assert.Equal(t, 250*time.Millisecond, d)
+	assert.True(t, r.fire(start, emit))
+	assert.Empty(t, got)
+
+	// a repeat followed by a SYN_REPORT once the delay has passed
+	now := start.Add(300 * time.Millisecond)
+	assert.True(t, r.fire(now, emit))
+	assert.Len(t, got, 2)
+	assert.Equal(t, 2, got[0].Value)
+	assert.Equal(t, 'a', got[0].AsRune)
+	assert.Equal(t, now, got[0].Time)
+	assert.Equal(t, "SYN_REPORT", got[1].EventName)
+	d, _ = r.due(now)
+	assert.Equal(t, 30*time.Millisecond, d)
+
+	// emit stopping is passed back
+	assert.False(t, r.fire(now.Add(time.Second), func(KeyEvent) bool { return false }))
+}
+
+func Test_keyRepeat_fireModifiers(t *testing.T) {
+	const keyA = 30
+	kbd := &KeyboardDevice{modifiers: &KeyModifiers{}}
+	kbd.modifiers.SetKey(keyLeftShift, true)
+	start := time.Now()
+	r := &keyRepeat{delay: 250 * time.Millisecond, period: 30 * time.Millisecond}
+	press := NewKeyEvent(InputEvent{Type: evKey, Code: keyA, Value: 1})
+	press.Device = kbd
+	press.Modifiers = *kbd.modifiers
+	press.updateRune(kbd.Layout(), kbd.modifiers)
+	r.filter(RepeatSynthesise, *press, start)
+	var got []KeyEvent
+	emit := func(e KeyEvent) bool {
+		got = append(got, e)
+		return true
+	}
+
+	// the repeats follow Shift being let go while the key is held
+	assert.True(t, r.fire(start.Add(300*time.Millisecond), emit))
+	kbd.modifiers.SetKey(keyLeftShift, false)
+	assert.True(t, r.fire(start.Add(400*time.Millisecond), emit))
+	if assert.Len(t, got, 4) {
+		assert.Equal(t, 'A', got[0].AsRune)
+		assert.True(t, got[0].Modifiers.Shift)
+		assert.Equal(t, 'a', got[2].AsRune)
+		assert.False(t, got[2].Modifiers.Shift)
+	}
+}
@@ -5,11 +5,63 @@
 
 package gokbd
 
-// CharVariants represents the upper and lower case variants for a charactor
+// CharVariants represents the upper and lower case variants for a charactor,
+// and those typed with AltGr (the third and fourth shift levels)
 type CharVariants struct {
-	lc rune
-	uc rune
-	// cc rune
+	lc  rune
+	uc  rune
+	l3  rune
+	l3s rune
+}
+
+// levels returns the characters for each shift level, dropping the AltGr
+// levels if there are none
+func (v CharVariants) levels() []rune {
+	if v.l3 == 0 && v.l3s == 0 {
+		return []rune{v.lc, v.uc}
+	}
+	return []rune{v.lc, v.uc, v.l3, v.l3s}
+}
+
+// altGrModifiers are the level modifier keys of layouts with AltGr
+var altGrModifiers = map[int]Level{
+	keyRightAlt: LevelThree,
+}
+
+// gbDeadKeys are the dead keys of the UK layout, by key code and level
+var gbDeadKeys = map[int][]string{
+	13: {"", "", "dead_cedilla", "dead_ogonek"},
+	26: {"", "", "dead_diaeresis", "dead_abovering"},
+	27: {"", "", "dead_tilde", "dead_macron"},
+	36: {"", "", "dead_hook", "dead_horn"},
+	39: {"", "", "dead_acute", "dead_doubleacute"},
+	40: {"", "", "dead_circumflex", "dead_caron"},
+	43: {"", "", "dead_grave", "dead_breve"},
+	53: {"", "", "dead_belowdot", "dead_abovedot"},
+}
+
+// deDeadKeys are the dead keys of the German layout, by key code and level
+var deDeadKeys = map[int][]string{
+	13: {"dead_acute", "dead_grave", "dead_cedilla", "dead_ogonek"},
+	26: {"", "", "dead_diaeresis", "dead_abovering"},
+	36: {"", "", "dead_belowdot", "dead_abovedot"},
+	39: {"", "", "dead_doubleacute", "dead_belowdot"},
+	40: {"", "", "dead_circumflex", "dead_caron"},
+	41: {"dead_circumflex"},
+	43: {"", "", "", "dead_breve"},
+	86: {"", "", "", "dead_belowmacron"},
+}
+
+// frDeadKeys are the dead keys of the French layout, by key code and level
+var frDeadKeys = map[int][]string{
+	13: {"", "", "", "dead_ogonek"},
+	26: {"dead_circumflex", "dead_diaeresis", "dead_diaeresis", "dead_abovering"},
+	27: {"", "", "", "dead_macron"},
+	36: {"", "", "dead_hook", "dead_horn"},
+	40: {"", "", "dead_circumflex", "dead_caron"},
+	43: {"", "", "dead_grave", "dead_breve"},
+	50: {"", "", "dead_acute", "dead_doubleacute"},
+	53: {"", "", "dead_belowdot", "dead_abovedot"},
 }
 
 var runeMap = map[int]CharVariants{
@@ -66,16 +118,314 @@ var runeMap = map[int]CharVariants{
 	57: {lc: ' ', uc: ' '},
 }
 
+// gbRuneMap is the UK QWERTY layout. The AltGr dead keys produce no rune,
+// see gbDeadKeys.
+var gbRuneMap = map[int]CharVariants{
+	2:  {lc: '1', uc: '!', l3: '¹', l3s: '¡'},
+	3:  {lc: '2', uc: '"', l3: '²', l3s: '⅛'},
+	4:  {lc: '3', uc: '£', l3: '³', l3s: '£'},
+	5:  {lc: '4', uc: '$', l3: '€', l3s: '¼'},
+	6:  {lc: '5', uc: '%', l3: '½', l3s: '⅜'},
+	7:  {lc: '6', uc: '^', l3: '¾', l3s: '⅝'},
+	8:  {lc: '7', uc: '&', l3: '{', l3s: '⅞'},
+	9:  {lc: '8', uc: '*', l3: '[', l3s: '™'},
+	10: {lc: '9', uc: '(', l3: ']', l3s: '±'},
+	11: {lc: '0', uc: ')', l3: '}', l3s: '°'},
+	12: {lc: '-', uc: '_', l3: '\\', l3s: '¿'},
+	13: {lc: '=', uc: '+'},
+	14: {lc: '\b', uc: '\b'},
+	15: {lc: '\t', uc: '\t'},
+	16: {lc: 'q', uc: 'Q', l3: '@', l3s: 'Ω'},
+	17: {lc: 'w', uc: 'W', l3: 'ſ', l3s: '§'},
+	18: {lc: 'e', uc: 'E', l3: 'e', l3s: 'E'},
+	19: {lc: 'r', uc: 'R', l3: '¶', l3s: '®'},
+	20: {lc: 't', uc: 'T', l3: 'ŧ', l3s: 'Ŧ'},
+	21: {lc: 'y', uc: 'Y', l3: '←', l3s: '¥'},
+	22: {lc: 'u', uc: 'U', l3: '↓', l3s: '↑'},
+	23: {lc: 'i', uc: 'I', l3: '→', l3s: 'ı'},
+	24: {lc: 'o', uc: 'O', l3: 'ø', l3s: 'Ø'},
+	25: {lc: 'p', uc: 'P', l3: 'þ', l3s: 'Þ'},
+	26: {lc: '[', uc: '{'},
+	27: {lc: ']', uc: '}'},
+	28: {lc: '\n', uc: '\n'},
+	30: {lc: 'a', uc: 'A', l3: 'æ', l3s: 'Æ'},
+	31: {lc: 's', uc: 'S', l3: 'ß', l3s: 'ẞ'},
+	32: {lc: 'd', uc: 'D', l3: 'ð', l3s: 'Ð'},
+	33: {lc: 'f', uc: 'F', l3: 'đ', l3s: 'ª'},
+	34: {lc: 'g', uc: 'G', l3: 'ŋ', l3s: 'Ŋ'},
+	35: {lc: 'h', uc: 'H', l3: 'ħ', l3s: 'Ħ'},
+	36: {lc: 'j', uc: 'J'},
+	37: {lc: 'k', uc: 'K', l3: 'ĸ', l3s: '&'},
+	38: {lc: 'l', uc: 'L', l3: 'ł', l3s: 'Ł'},
+	39: {lc: ';', uc: ':'},
+	40: {lc: '\'', uc: '@'},
+	41: {lc: '`', uc: '¬', l3: '|', l3s: '|'},
+	43: {lc: '#', uc: '~'},
+	44: {lc: 'z', uc: 'Z', l3: '«', l3s: '<'},
+	45: {lc: 'x', uc: 'X', l3: '»', l3s: '>'},
+	46: {lc: 'c', uc: 'C', l3: '¢', l3s: '©'},
+	47: {lc: 'v', uc: 'V', l3: '„', l3s: '‚'},
+	48: {lc: 'b', uc: 'B', l3: '“', l3s: '‘'},
+	49: {lc: 'n', uc: 'N', l3: '”', l3s: '’'},
+	50: {lc: 'm', uc: 'M', l3: 'µ', l3s: 'º'},
+	51: {lc: ',', uc: '<', l3: '•', l3s: '×'},
+	52: {lc: '.', uc: '>', l3: '·', l3s: '÷'},
+	53: {lc: '/', uc: '?'},
+	57: {lc: ' ', uc: ' '},
+	86: {lc: '\\', uc: '|', l3: '|', l3s: '¦'},
+}
+
+// deRuneMap is the German QWERTZ layout. The dead keys (^, the accents and
+// those on AltGr) produce no rune, see deDeadKeys.
+var deRuneMap = map[int]CharVariants{
+	2:  {lc: '1', uc: '!', l3: '¹', l3s: '¡'},
+	3:  {lc: '2', uc: '"', l3: '²', l3s: '⅛'},
+	4:  {lc: '3', uc: '§', l3: '³', l3s: '£'},
+	5:  {lc: '4', uc: '$', l3: '¼', l3s: '¤'},
+	6:  {lc: '5', uc: '%', l3: '½', l3s: '⅜'},
+	7:  {lc: '6', uc: '&', l3: '¬', l3s: '⅝'},
+	8:  {lc: '7', uc: '/', l3: '{', l3s: '⅞'},
+	9:  {lc: '8', uc: '(', l3: '[', l3s: '™'},
+	10: {lc: '9', uc: ')', l3: ']', l3s: '±'},
+	11: {lc: '0', uc: '=', l3: '}', l3s: '°'},
+	12: {lc: 'ß', uc: '?', l3: '\\', l3s: '¿'},
+	14: {lc: '\b', uc: '\b'},
+	15: {lc: '\t', uc: '\t'},
+	16: {lc: 'q', uc: 'Q', l3: '@', l3s: 'Ω'},
+	17: {lc: 'w', uc: 'W', l3: 'ſ', l3s: '§'},
+	18: {lc: 'e', uc: 'E', l3: '€', l3s: '€'},
+	19: {lc: 'r', uc: 'R', l3: '¶', l3s: '®'},
+	20: {lc: 't', uc: 'T', l3: 'ŧ', l3s: 'Ŧ'},
+	21: {lc: 'z', uc: 'Z', l3: '←', l3s: '¥'},
+	22: {lc: 'u', uc: 'U', l3: '↓', l3s: '↑'},
+	23: {lc: 'i', uc: 'I', l3: '→', l3s: 'ı'},
+	24: {lc: 'o', uc: 'O', l3: 'ø', l3s: 'Ø'},
+	25: {lc: 'p', uc: 'P', l3: 'þ', l3s: 'Þ'},
+	26: {lc: 'ü', uc: 'Ü'},
+	27: {lc: '+', uc: '*', l3: '~', l3s: '¯'},
+	28: {lc: '\n', uc: '\n'},
+	30: {lc: 'a', uc: 'A', l3: 'æ', l3s: 'Æ'},
+	31: {lc: 's', uc: 'S', l3: 'ſ', l3s: 'ẞ'},
+	32: {lc: 'd', uc: 'D', l3: 'ð', l3s: 'Ð'},
+	33: {lc: 'f', uc: 'F', l3: 'đ', l3s: 'ª'},
+	34: {lc: 'g', uc: 'G', l3: 'ŋ', l3s: 'Ŋ'},
+	35: {lc: 'h', uc: 'H', l3: 'ħ', l3s: 'Ħ'},
+	36: {lc: 'j', uc: 'J'},
+	37: {lc: 'k', uc: 'K', l3: 'ĸ', l3s: '&'},
+	38: {lc: 'l', uc: 'L', l3: 'ł', l3s: 'Ł'},
+	39: {lc: 'ö', uc: 'Ö'},
+	40: {lc: 'ä', uc: 'Ä'},
+	41: {uc: '°', l3: '′', l3s: '″'},
+	43: {lc: '#', uc: '\'', l3: '’'},
+	44: {lc: 'y', uc: 'Y', l3: '»', l3s: '›'},
+	45: {lc: 'x', uc: 'X', l3: '«', l3s: '‹'},
+	46: {lc: 'c', uc: 'C', l3: '¢', l3s: '©'},
+	47: {lc: 'v', uc: 'V', l3: '„', l3s: '‚'},
+	48: {lc: 'b', uc: 'B', l3: '“', l3s: '‘'},
+	49: {lc: 'n', uc: 'N', l3: '”', l3s: '’'},
+	50: {lc: 'm', uc: 'M', l3: 'µ', l3s: 'º'},
+	51: {lc: ',', uc: ';', l3: '·', l3s: '×'},
+	52: {lc: '.', uc: ':', l3: '…', l3s: '÷'},
+	53: {lc: '-', uc: '_', l3: '–', l3s: '—'},
+	57: {lc: ' ', uc: ' '},
+	86: {lc: '<', uc: '>', l3: '|'},
+}
+
+// frRuneMap is the French AZERTY layout. The dead keys (^ and ¨ and those on
+// AltGr) produce no rune, see frDeadKeys.
+var frRuneMap = map[int]CharVariants{
+	2:  {lc: '&', uc: '1', l3: '¹', l3s: '¡'},
+	3:  {lc: 'é', uc: '2', l3: '~', l3s: '⅛'},
+	4:  {lc: '"', uc: '3', l3: '#', l3s: '£'},
+	5:  {lc: '\'', uc: '4', l3: '{', l3s: '$'},
+	6:  {lc: '(', uc: '5', l3: '[', l3s: '⅜'},
+	7:  {lc: '-', uc: '6', l3: '|', l3s: '⅝'},
+	8:  {lc: 'è', uc: '7', l3: '`', l3s: '⅞'},
+	9:  {lc: '_', uc: '8', l3: '\\', l3s: '™'},
+	10: {lc: 'ç', uc: '9', l3: '^', l3s: '±'},
+	11: {lc: 'à', uc: '0', l3: '@', l3s: '°'},
+	12: {lc: ')', uc: '°', l3: ']', l3s: '¿'},
+	13: {lc: '=', uc: '+', l3: '}'},
+	14: {lc: '\b', uc: '\b'},
+	15: {lc: '\t', uc: '\t'},
+	16: {lc: 'a', uc: 'A', l3: 'æ', l3s: 'Æ'},
+	17: {lc: 'z', uc: 'Z', l3: '«', l3s: '<'},
+	18: {lc: 'e', uc: 'E', l3: '€', l3s: '¢'},
+	19: {lc: 'r', uc: 'R', l3: '¶', l3s: '®'},
+	20: {lc: 't', uc: 'T', l3: 'ŧ', l3s: 'Ŧ'},
+	21: {lc: 'y', uc: 'Y', l3: '←', l3s: '¥'},
+	22: {lc: 'u', uc: 'U', l3: '↓', l3s: '↑'},
+	23: {lc: 'i', uc: 'I', l3: '→', l3s: 'ı'},
+	24: {lc: 'o', uc: 'O', l3: 'ø', l3s: 'Ø'},
+	25: {lc: 'p', uc: 'P', l3: 'þ', l3s: 'Þ'},
+	27: {lc: '$', uc: '£', l3: '¤'},
+	28: {lc: '\n', uc: '\n'},
+	30: {lc: 'q', uc: 'Q', l3: '@', l3s: 'Ω'},
+	31: {lc: 's', uc: 'S', l3: 'ß', l3s: 'ẞ'},
+	32: {lc: 'd', uc: 'D', l3: 'ð', l3s: 'Ð'},
+	33: {lc: 'f', uc: 'F', l3: 'đ', l3s: 'ª'},
+	34: {lc: 'g', uc: 'G', l3: 'ŋ', l3s: 'Ŋ'},
+	35: {lc: 'h', uc: 'H', l3: 'ħ', l3s: 'Ħ'},
+	36: {lc: 'j', uc: 'J'},
+	37: {lc: 'k', uc: 'K', l3: 'ĸ', l3s: '&'},
+	38: {lc: 'l', uc: 'L', l3: 'ł', l3s: 'Ł'},
+	39: {lc: 'm', uc: 'M', l3: 'µ', l3s: 'º'},
+	40: {lc: 'ù', uc: '%'},
+	41: {lc: '²', uc: '~', l3: '¬', l3s: '¬'},
+	43: {lc: '*', uc: 'µ'},
+	44: {lc: 'w', uc: 'W', l3: 'ł', l3s: 'Ł'},
+	45: {lc: 'x', uc: 'X', l3: '»', l3s: '>'},
+	46: {lc: 'c', uc: 'C', l3: '¢', l3s: '©'},
+	47: {lc: 'v', uc: 'V', l3: '„', l3s: '‚'},
+	48: {lc: 'b', uc: 'B', l3: '“', l3s: '‘'},
+	49: {lc: 'n', uc: 'N', l3: '”', l3s: '’'},
+	50: {lc: ',', uc: '?'},
+	51: {lc: ';', uc: '.', l3: '•', l3s: '×'},
+	52: {lc: ':', uc: '/', l3: '·', l3s: '÷'},
+	53: {lc: '!', uc: '§'},
+	57: {lc: ' ', uc: ' '},
+	86: {lc: '<', uc: '>', l3: '|', l3s: '¦'},
+}
+
+// dvorakRuneMap is the US Dvorak layout
+var dvorakRuneMap = map[int]CharVariants{
+	2:  {lc: '1', uc: '!'},
+	3:  {lc: '2', uc: '@'},
+	4:  {lc: '3', uc: '#'},
+	5:  {lc: '4', uc: '$'},
+	6:  {lc: '5', uc: '%'},
+	7:  {lc: '6', uc: '^'},
+	8:  {lc: '7', uc: '&'},
+	9:  {lc: '8', uc: '*'},
+	10: {lc: '9', uc: '('},
+	11: {lc: '0', uc: ')'},
+	12: {lc: '[', uc: '{'},
+	13: {lc: ']', uc: '}'},
+	14: {lc: '\b', uc: '\b'},
+	15: {lc: '\t', uc: '\t'},
+	16: {lc: '\'', uc: '"'},
+	17: {lc: ',', uc: '<'},
+	18: {lc: '.', uc: '>'},
+	19: {lc: 'p', uc: 'P'},
+	20: {lc: 'y', uc: 'Y'},
+	21: {lc: 'f', uc: 'F'},
+	22: {lc: 'g', uc: 'G'},
+	23: {lc: 'c', uc: 'C'},
+	24: {lc: 'r', uc: 'R'},
+	25: {lc: 'l', uc: 'L'},
+	26: {lc: '/', uc: '?'},
+	27: {lc: '=', uc: '+'},
+	28: {lc: '\n', uc: '\n'},
+	30: {lc: 'a', uc: 'A'},
+	31: {lc: 'o', uc: 'O'},
+	32: {lc: 'e', uc: 'E'},
+	33: {lc: 'u', uc: 'U'},
+	34: {lc: 'i', uc: 'I'},
+	35: {lc: 'd', uc: 'D'},
+	36: {lc: 'h', uc: 'H'},
+	37: {lc: 't', uc: 'T'},
+	38: {lc: 'n', uc: 'N'},
+	39: {lc: 's', uc: 'S'},
+	40: {lc: '-', uc: '_'},
+	41: {lc: '`', uc: '~'},
+	43: {lc: '\\', uc: '|'},
+	44: {lc: ';', uc: ':'},
+	45: {lc: 'q', uc: 'Q'},
+	46: {lc: 'j', uc: 'J'},
+	47: {lc: 'k', uc: 'K'},
+	48: {lc: 'x', uc: 'X'},
+	49: {lc: 'b', uc: 'B'},
+	50: {lc: 'm', uc: 'M'},
+	51: {lc: 'w', uc: 'W'},
+	52: {lc: 'v', uc: 'V'},
+	53: {lc: 'z', uc: 'Z'},
+	57: {lc: ' ', uc: ' '},
+}
+
+// colemakRuneMap is the Colemak layout
+var colemakRuneMap = map[int]CharVariants{
+	2:  {lc: '1', uc: '!'},
+	3:  {lc: '2', uc: '@'},
+	4:  {lc: '3', uc: '#'},
+	5:  {lc: '4', uc: '$'},
+	6:  {lc: '5', uc: '%'},
+	7:  {lc: '6', uc: '^'},
+	8:  {lc: '7', uc: '&'},
+	9:  {lc: '8', uc: '*'},
+	10: {lc: '9', uc: '('},
+	11: {lc: '0', uc: ')'},
+	12: {lc: '-', uc: '_'},
+	13: {lc: '=', uc: '+'},
+	14: {lc: '\b', uc: '\b'},
+	15: {lc: '\t', uc: '\t'},
+	16: {lc: 'q', uc: 'Q'},
+	17: {lc: 'w', uc: 'W'},
+	18: {lc: 'f', uc: 'F'},
+	19: {lc: 'p', uc: 'P'},
+	20: {lc: 'g', uc: 'G'},
+	21: {lc: 'j', uc: 'J'},
+	22: {lc: 'l', uc: 'L'},
+	23: {lc: 'u', uc: 'U'},
+	24: {lc: 'y', uc: 'Y'},
+	25: {lc: ';', uc: ':'},
+	26: {lc: '[', uc: '{'},
+	27: {lc: ']', uc: '}'},
+	28: {lc: '\n', uc: '\n'},
+	30: {lc: 'a', uc: 'A'},
+	31: {lc: 'r', uc: 'R'},
+	32: {lc: 's', uc: 'S'},
+	33: {lc: 't', uc: 'T'},
+	34: {lc: 'd', uc: 'D'},
+	35: {lc: 'h', uc: 'H'},
+	36: {lc: 'n', uc: 'N'},
+	37: {lc: 'e', uc: 'E'},
+	38: {lc: 'i', uc: 'I'},
+	39: {lc: 'o', uc: 'O'},
+	40: {lc: '\'', uc: '"'},
+	41: {lc: '`', uc: '~'},
+	43: {lc: '\\', uc: '|'},
+	44: {lc: 'z', uc: 'Z'},
+	45: {lc: 'x', uc: 'X'},
+	46: {lc: 'c', uc: 'C'},
+	47: {lc: 'v', uc: 'V'},
+	48: {lc: 'b', uc: 'B'},
+	49: {lc: 'k', uc: 'K'},
+	50: {lc: 'm', uc: 'M'},
+	51: {lc: ',', uc: '<'},
+	52: {lc: '.', uc: '>'},
+	53: {lc: '/', uc: '?'},
+	57: {lc: ' ', uc: ' '},
+}
+
+// keypadMap holds the runes for the keypad keys that produce digits when
+// NumLock is on and act as navigation keys (producing no rune) otherwise
+var keypadMap = map[int]rune{
+	71: '7',
+	72: '8',
+	73: '9',
+	75: '4',
+	76: '5',
+	77: '6',
+	79: '1',
+	80: '2',
+	81: '3',
+	82: '0',
+	83: '.',
+}
+
+// keypadOpMap holds the runes for the keypad keys that are not affected by
+// NumLock
+var keypadOpMap = map[int]rune{
+	55: '*',
+	74: '-',
+	78: '+',
+	96: '\n',
+	98: '/',
+}
+
 // CodeAndCase returns the keycode and whether the key was
-// an upper or lowercase rune for the typed key
+// an upper or lowercase rune for the typed key, using the default "us" layout
 func CodeAndCase(r rune) (int, bool) {
-	for k, v := range runeMap {
-		switch {
-		case r == v.lc:
-			return k, false
-		case r == v.uc:
-			return k, true
-		}
-	}
-	return 0, false
+	code, level := DefaultLayout().Code(r)
+	return code, level&LevelShift != 0
 }
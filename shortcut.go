@@ -0,0 +1,92 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ParseShortcut will parse a keyboard shortcut such as "ctrl+alt+t" or
+// "Super+L" into its keys, in the order they are pressed. Each key name is
+// separated by a "+" and parsed with ParseKey, so the modifiers can be given
+// by their common names (ctrl, shift, alt, altgr, super, meta...).
+func ParseShortcut(shortcut string) ([]Key, error) {
+	if strings.TrimSpace(shortcut) == "" {
+		return nil, errors.New("empty shortcut")
+	}
+	var keys []Key
+	for _, name := range strings.Split(shortcut, "+") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, fmt.Errorf("invalid shortcut %q: empty key name", shortcut)
+		}
+		k, err := ParseKey(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid shortcut %q: %w", shortcut, err)
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// TypeChord will press the keys in order, holding each down, then release
+// them in reverse. The modifiers come first and the key they modify last, for
+// example TypeChord(KeyLeftCtrl, KeyLeftAlt, KeyT). The keys must be enabled
+// on the virtual keyboard. If writing an event fails, every key already
+// pressed (including the one that failed) is still released, so no key is
+// left held down. Keys held down with Press are left held. The chord is
+// written as a whole, so no other typing on the virtual keyboard happens
+// while its modifiers are held.
+func (u *VirtualKeyboardDevice) TypeChord(keys ...Key) error {
+	return u.TypeChordContext(context.Background(), keys...)
+}
+
+// TypeChordContext will type the chord, as TypeChord, unless the context is
+// cancelled first. Cancelling the context while the chord is typed cuts the
+// typing profile's pauses short, but every key is still released.
+func (u *VirtualKeyboardDevice) TypeChordContext(ctx context.Context, keys ...Key) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return errors.New("no keys in chord")
+	}
+	seen := make(map[Key]bool, len(keys))
+	mods := make([]int, 0, len(keys)-1)
+	for i, k := range keys {
+		if seen[k] {
+			return fmt.Errorf("key %s is repeated in chord", k)
+		}
+		seen[k] = true
+		if i < len(keys)-1 {
+			mods = append(mods, int(k))
+		}
+	}
+	return u.sendKeySequence(ctx, chordKeys(int(keys[len(keys)-1]), mods...))
+}
+
+// SendShortcut will type the keyboard shortcut, as parsed by ParseShortcut,
+// with TypeChord
+func (u *VirtualKeyboardDevice) SendShortcut(shortcut string) error {
+	keys, err := ParseShortcut(shortcut)
+	if err != nil {
+		return err
+	}
+	return u.TypeChord(keys...)
+}
+
+// SendShortcutContext will type the keyboard shortcut, as SendShortcut, with
+// TypeChordContext
+func (u *VirtualKeyboardDevice) SendShortcutContext(ctx context.Context, shortcut string) error {
+	keys, err := ParseShortcut(shortcut)
+	if err != nil {
+		return err
+	}
+	return u.TypeChordContext(ctx, keys...)
+}
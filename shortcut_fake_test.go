@@ -0,0 +1,121 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/joshuar/gokbd"
+	"github.com/joshuar/gokbd/gokbdtest"
+	"github.com/stretchr/testify/assert"
+)
+
+// failingSink is a Sink where only the write with the given index fails
+type failingSink struct {
+	*gokbdtest.Sink
+	failAt int
+	writes int
+}
+
+func (f *failingSink) WriteEvent(ev gokbd.InputEvent) error {
+	f.writes++
+	if f.writes-1 == f.failAt {
+		return errors.New("write failed")
+	}
+	return f.Sink.WriteEvent(ev)
+}
+
+func (f *failingSink) WriteEvents(evs ...gokbd.InputEvent) error {
+	for _, ev := range evs {
+		if err := f.WriteEvent(ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestVirtualKeyboardDevice_TypeChord(t *testing.T) {
+	v, sink := newVirtualKeyboard(t, gokbd.WithKeys(gokbd.KeyEsc))
+
+	assert.Nil(t, v.SendShortcut("ctrl+shift+esc"))
+	var got []gokbd.InputEvent
+	for _, ev := range keyEvents(sink) {
+		if ev.Type == uint16(gokbd.EventKey) {
+			got = append(got, ev)
+		}
+	}
+	assert.Equal(t, []gokbd.InputEvent{
+		keyEvent(gokbd.KeyLeftCtrl, 1),
+		keyEvent(gokbd.KeyLeftShift, 1),
+		keyEvent(gokbd.KeyEsc, 1),
+		keyEvent(gokbd.KeyEsc, 0),
+		keyEvent(gokbd.KeyLeftShift, 0),
+		keyEvent(gokbd.KeyLeftCtrl, 0),
+	}, got)
+	assert.Len(t, sink.Events(), 12)
+
+	sink.Reset()
+	assert.NotNil(t, v.SendShortcut("ctrl+f5"))
+	assert.NotNil(t, v.TypeChord(gokbd.KeyLeftCtrl, gokbd.KeyLeftCtrl))
+	assert.NotNil(t, v.TypeChord())
+	assert.NotNil(t, v.SendShortcut("ctrl+nothing"))
+	assert.Empty(t, sink.Events())
+}
+
+func TestVirtualKeyboardDevice_TypeChordFailure(t *testing.T) {
+	sink, err := gokbdtest.NewSink()
+	assert.Nil(t, err)
+	// Ctrl and its SYN_REPORT are written, then the Alt press fails.
+	v := newVirtualKeyboardWithSink(t, &failingSink{Sink: sink, failAt: 2})
+
+	assert.NotNil(t, v.SendShortcut("ctrl+alt+t"))
+	// Every key of the failed write is released, in reverse.
+	assert.Equal(t, []gokbd.InputEvent{
+		keyEvent(gokbd.KeyLeftCtrl, 1), syncEvent(),
+		keyEvent(gokbd.KeyT, 0),
+		keyEvent(gokbd.KeyLeftAlt, 0),
+		keyEvent(gokbd.KeyLeftCtrl, 0), syncEvent(),
+	}, keyEvents(sink))
+
+	// With a dwell, the chord is written a key at a time, and only the keys
+	// pressed so far are released.
+	failing, err := gokbdtest.NewSink()
+	assert.Nil(t, err)
+	w := newVirtualKeyboardWithSink(t, &failingSink{Sink: failing, failAt: 2},
+		gokbd.WithTypingProfile(gokbd.TypingProfile{Dwell: time.Millisecond}))
+	assert.NotNil(t, w.SendShortcut("ctrl+alt+t"))
+	assert.Equal(t, []gokbd.InputEvent{
+		keyEvent(gokbd.KeyLeftCtrl, 1), syncEvent(),
+		keyEvent(gokbd.KeyLeftAlt, 0),
+		keyEvent(gokbd.KeyLeftCtrl, 0), syncEvent(),
+	}, keyEvents(failing))
+}
+
+func TestVirtualKeyboardDevice_TypeChordConcurrent(t *testing.T) {
+	v, sink := newVirtualKeyboard(t,
+		gokbd.WithTypingProfile(gokbd.TypingProfile{Dwell: 5 * time.Millisecond}))
+
+	done := make(chan error)
+	go func() {
+		done <- v.TypeString("bbbbbbbbbb")
+	}()
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, v.TypeChord(gokbd.KeyLeftCtrl, gokbd.KeyA))
+	}
+	assert.Nil(t, <-done)
+	// B is never pressed while Ctrl is held.
+	var ctrl bool
+	for _, ev := range keyEvents(sink) {
+		switch ev {
+		case keyEvent(gokbd.KeyLeftCtrl, 1), keyEvent(gokbd.KeyLeftCtrl, 0):
+			ctrl = ev.Value == 1
+		case keyEvent(gokbd.KeyB, 1):
+			assert.False(t, ctrl, "b typed with Ctrl held")
+		}
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseShortcut(t *testing.T) {
+	tests := []struct {
+		shortcut string
+		want     []Key
+		wantErr  bool
+	}{
+		{shortcut: "ctrl+alt+t", want: []Key{KeyLeftCtrl, KeyLeftAlt, KeyT}},
+		{shortcut: "Super+L", want: []Key{KeyLeftMeta, KeyL}},
+		{shortcut: "Ctrl + Shift + Esc", want: []Key{KeyLeftCtrl, KeyLeftShift, KeyEsc}},
+		{shortcut: "KEY_RIGHTCTRL+f4", want: []Key{KeyRightCtrl, KeyF4}},
+		{shortcut: "volumeup", want: []Key{KeyVolumeUp}},
+		{shortcut: "", wantErr: true},
+		{shortcut: "  ", wantErr: true},
+		{shortcut: "ctrl+", wantErr: true},
+		{shortcut: "ctrl++t", wantErr: true},
+		{shortcut: "ctrl+nothing", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.shortcut, func(t *testing.T) {
+			got, err := ParseShortcut(tt.shortcut)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
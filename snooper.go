@@ -0,0 +1,504 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sys/unix"
+)
+
+// errorBufferSize is the number of device errors a Snooper will hold for a
+// receiver before dropping them
+const errorBufferSize = 16
+
+// ErrDeviceRemoved is reported when a keyboard being snooped is disconnected
+var ErrDeviceRemoved = errors.New("device removed")
+
+// DeviceError represents an error snooping on a specific keyboard
+type DeviceError struct {
+	Device *KeyboardDevice
+	Err    error
+}
+
+func (e *DeviceError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Device.DevNode, e.Err)
+}
+
+func (e *DeviceError) Unwrap() error {
+	return e.Err
+}
+
+// Snooper snoops or listens for key events on one or more keyboards. Key
+// events are passed out through Keys and any errors reading a keyboard
+// (including it being disconnected) are passed out through Errors as a
+// *DeviceError. Both channels are closed once snooping has stopped, which
+// happens when the context is cancelled or every keyboard has failed.
+//
+// All keyboards are read from a single goroutine using epoll, so the cost of
+// snooping does not grow with the number of keyboards. A Snooper takes
+// ownership of the keyboards it snoops on and closes each of them once it is
+// detached, so they should not be closed or read elsewhere.
+type Snooper struct {
+	keys    chan KeyEvent
+	errs    chan error
+	done    chan struct{}
+	devices map[int32]*KeyboardDevice
+	repeats map[int32]*keyRepeat
+	compose map[int32]*composer
+	opts    snooperOptions
+	err     error
+	runErr  error
+	devErrs []error
+	pending []snoopCmd
+	mu      sync.Mutex
+	epfd    int
+	wakefd  int
+	hotplug bool
+	stopped bool
+}
+
+// SnooperOption configures a Snooper, see WithFilters, WithRepeatMode and
+// WithCompose
+type SnooperOption func(*snooperOptions)
+
+type snooperOptions struct {
+	filters      []DeviceFilter
+	repeatMode   RepeatMode
+	composeTable *ComposeTable
+}
+
+// WithFilters will make a hotplug Snooper only snoop on keyboards matching at
+// least one of the filters. It has no effect on NewSnooper, which snoops on
+// the keyboards it is given.
+func WithFilters(filters ...DeviceFilter) SnooperOption {
+	return func(o *snooperOptions) {
+		o.filters = append(o.filters, filters...)
+	}
+}
+
+// WithRepeatMode sets how the Snooper handles autorepeat events, see
+// RepeatMode. By default they are passed through as reported.
+func WithRepeatMode(mode RepeatMode) SnooperOption {
+	return func(o *snooperOptions) {
+		o.repeatMode = mode
+	}
+}
+
+// WithCompose will make the Snooper combine dead key and Compose key sequences
+// from the table into the characters they produce, so for example a dead
+// acute followed by e is passed out as a press of e with an AsRune (and
+// Composed) of "é". The presses that start or continue a sequence have no
+// AsRune. Sequences are tracked for each keyboard separately, using its
+// layout to find the dead keys. See LoadLocaleComposeTable for loading the
+// system table.
+func WithCompose(table *ComposeTable) SnooperOption {
+	return func(o *snooperOptions) {
+		o.composeTable = table
+	}
+}
+
+func newSnooperOptions(opts []SnooperOption) snooperOptions {
+	var o snooperOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// snoopCmd asks the snooping goroutine to attach or detach a keyboard
+type snoopCmd struct {
+	kbd    *KeyboardDevice
+	remove bool
+}
+
+// newSnooper will set up the epoll instance and eventfd used to wait for key
+// events and wake the snooping goroutine. If hotplug is true, the Snooper keeps
+// running when it has no keyboards.
+func newSnooper(hotplug bool, opts snooperOptions) (*Snooper, error) {
+	epfd, err := unix.EpollCreate1(unix.EPOLL_CLOEXEC)
+	if err != nil {
+		return nil, fmt.Errorf("could not create epoll instance: %w", err)
+	}
+	wakefd, err := unix.Eventfd(0, unix.EFD_NONBLOCK|unix.EFD_CLOEXEC)
+	if err != nil {
+		unix.Close(epfd)
+		return nil, fmt.Errorf("could not create eventfd: %w", err)
+	}
+	wakeEv := &unix.EpollEvent{Events: unix.EPOLLIN, Fd: int32(wakefd)}
+	if err := unix.EpollCtl(epfd, unix.EPOLL_CTL_ADD, wakefd, wakeEv); err != nil {
+		unix.Close(wakefd)
+		unix.Close(epfd)
+		return nil, fmt.Errorf("could not watch eventfd: %w", err)
+	}
+	return &Snooper{
+		keys:    make(chan KeyEvent, 1),
+		errs:    make(chan error, errorBufferSize),
+		done:    make(chan struct{}),
+		devices: make(map[int32]*KeyboardDevice),
+		repeats: make(map[int32]*keyRepeat),
+		compose: make(map[int32]*composer),
+		opts:    opts,
+		epfd:    epfd,
+		wakefd:  wakefd,
+		hotplug: hotplug,
+	}, nil
+}
+
+// Keys returns the channel on which key events are passed out
+func (s *Snooper) Keys() <-chan KeyEvent {
+	return s.keys
+}
+
+// Errors returns the channel on which errors reading keyboards are passed out.
+// Errors are always logged, and will be dropped from the channel if it is not
+// read.
+func (s *Snooper) Errors() <-chan error {
+	return s.errs
+}
+
+// Done returns a channel that is closed once snooping has stopped
+func (s *Snooper) Done() <-chan struct{} {
+	return s.done
+}
+
+// Wait will block until snooping has stopped and then return the reason, see
+// Err
+func (s *Snooper) Wait() error {
+	<-s.done
+	return s.err
+}
+
+// Err returns nil while snooping is ongoing. Once snooping has stopped, it
+// returns the context error if the context was cancelled, otherwise the
+// errors of the keyboards that failed.
+func (s *Snooper) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// wake will interrupt the snooping goroutine if it is waiting for events
+func (s *Snooper) wake() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return
+	}
+	if err := signalEventfd(s.wakefd); err != nil {
+		log.Error().Caller().Err(err).
+			Msg("Could not wake snooper.")
+	}
+}
+
+// queue will pass a command to the snooping goroutine. Keyboards queued after
+// snooping has stopped are closed.
+func (s *Snooper) queue(cmd snoopCmd) {
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		if !cmd.remove {
+			cmd.kbd.Close()
+		}
+		return
+	}
+	s.pending = append(s.pending, cmd)
+	s.mu.Unlock()
+	s.wake()
+}
+
+func (s *Snooper) applyPending() {
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+	for _, cmd := range pending {
+		if cmd.remove {
+			if s.devices[int32(cmd.kbd.src.Fd())] == cmd.kbd {
+				s.detach(cmd.kbd)
+				s.reportError(cmd.kbd, ErrDeviceRemoved)
+			}
+			continue
+		}
+		ev := &unix.EpollEvent{Events: unix.EPOLLIN, Fd: int32(cmd.kbd.src.Fd())}
+		if err := unix.EpollCtl(s.epfd, unix.EPOLL_CTL_ADD, cmd.kbd.src.Fd(), ev); err != nil {
+			s.reportError(cmd.kbd, fmt.Errorf("could not watch device: %w", err))
+			cmd.kbd.Close()
+			continue
+		}
+		log.Debug().Caller().
+			Msgf("Tracking keys on device %s.", cmd.kbd.DevNode)
+		repeat := &keyRepeat{}
+		if s.opts.repeatMode == RepeatSynthesise {
+			repeat = newKeyRepeat(cmd.kbd)
+		}
+		s.devices[int32(cmd.kbd.src.Fd())] = cmd.kbd
+		s.repeats[int32(cmd.kbd.src.Fd())] = repeat
+		s.compose[int32(cmd.kbd.src.Fd())] = &composer{table: s.opts.composeTable}
+	}
+}
+
+// detach will stop snooping on the given keyboard and close it
+func (s *Snooper) detach(kbd *KeyboardDevice) {
+	if err := unix.EpollCtl(s.epfd, unix.EPOLL_CTL_DEL, kbd.src.Fd(), nil); err != nil {
+		log.Debug().Caller().Err(err).
+			Msgf("Could not stop watching device %s.", kbd.DevNode)
+	}
+	delete(s.devices, int32(kbd.src.Fd()))
+	delete(s.repeats, int32(kbd.src.Fd()))
+	delete(s.compose, int32(kbd.src.Fd()))
+	kbd.Close()
+}
+
+func (s *Snooper) reportError(kbd *KeyboardDevice, err error) {
+	devErr := &DeviceError{Device: kbd, Err: err}
+	if errors.Is(err, ErrDeviceRemoved) {
+		log.Debug().Caller().
+			Msgf("Device %s has been removed.", kbd.DevNode)
+	} else {
+		log.Error().Err(err).
+			Msgf("Stopped tracking keys on device %s.", kbd.DevNode)
+	}
+	s.devErrs = append(s.devErrs, devErr)
+	select {
+	case s.errs <- devErr:
+	default:
+	}
+}
+
+// start will run the snooping goroutine until the context is cancelled
+func (s *Snooper) start(ctx context.Context) {
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.wake()
+		case <-s.done:
+		}
+	}()
+	go s.run(ctx)
+}
+
+func (s *Snooper) run(ctx context.Context) {
+	defer s.finish(ctx)
+	emit := func(e KeyEvent) bool {
+		select {
+		case <-ctx.Done():
+			return false
+		case s.keys <- e:
+			return true
+		}
+	}
+	events := make([]unix.EpollEvent, 16)
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+		s.applyPending()
+		if !s.hotplug && len(s.devices) == 0 {
+			return
+		}
+		n, err := unix.EpollWait(s.epfd, events, s.repeatTimeout(time.Now()))
+		if err != nil {
+			if errors.Is(err, unix.EINTR) {
+				continue
+			}
+			s.runErr = fmt.Errorf("could not wait for events: %w", err)
+			return
+		}
+		for _, ev := range events[:n] {
+			if int(ev.Fd) == s.wakefd {
+				var buf [8]byte
+				unix.Read(s.wakefd, buf[:])
+				continue
+			}
+			kbd, ok := s.devices[ev.Fd]
+			if !ok {
+				continue
+			}
+			repeat := s.repeats[ev.Fd]
+			compose := s.compose[ev.Fd]
+			err := kbd.readEvents(func(e KeyEvent) bool {
+				if !repeat.filter(s.opts.repeatMode, e, time.Now()) {
+					return true
+				}
+				compose.compose(&e)
+				return emit(e)
+			})
+			if err != nil {
+				s.detach(kbd)
+				s.reportError(kbd, err)
+			}
+		}
+		now := time.Now()
+		for fd, repeat := range s.repeats {
+			// synthesised repeats go through the composer, as the
+			// keyboard's own repeats do
+			compose := s.compose[fd]
+			if !repeat.fire(now, func(e KeyEvent) bool {
+				compose.compose(&e)
+				return emit(e)
+			}) {
+				return
+			}
+		}
+	}
+}
+
+// repeatTimeout will return how long (in milliseconds) to wait for events
+// before the next synthesised repeat is due, or -1 to wait indefinitely
+func (s *Snooper) repeatTimeout(now time.Time) int {
+	timeout := -1
+	for _, repeat := range s.repeats {
+		d, ok := repeat.due(now)
+		if !ok {
+			continue
+		}
+		// round up, so the repeat is due when epoll returns
+		ms := int((d + time.Millisecond - 1) / time.Millisecond)
+		if timeout < 0 || ms < timeout {
+			timeout = ms
+		}
+	}
+	return timeout
+}
+
+// finish will close all keyboards and the epoll instance, then record why
+// snooping stopped and close the channels
+func (s *Snooper) finish(ctx context.Context) {
+	s.mu.Lock()
+	s.stopped = true
+	pending := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+	for _, cmd := range pending {
+		if !cmd.remove {
+			cmd.kbd.Close()
+		}
+	}
+	for _, kbd := range s.devices {
+		s.detach(kbd)
+	}
+	unix.Close(s.wakefd)
+	unix.Close(s.epfd)
+	switch {
+	case ctx.Err() != nil:
+		s.err = ctx.Err()
+	case s.runErr != nil:
+		s.err = s.runErr
+	default:
+		s.err = errors.Join(s.devErrs...)
+	}
+	close(s.keys)
+	close(s.errs)
+	close(s.done)
+}
+
+// NewSnooper will start snooping on all keyboards passed in through the
+// channel, see OpenAllKeyboardDevices for an example of opening all connected
+// keyboards. Snooping stops when the context is cancelled or every keyboard
+// has failed.
+func NewSnooper(ctx context.Context, kbds <-chan *KeyboardDevice, opts ...SnooperOption) (*Snooper, error) {
+	s, err := newSnooper(false, newSnooperOptions(opts))
+	if err != nil {
+		for kbd := range kbds {
+			kbd.Close()
+		}
+		return nil, err
+	}
+	for kbd := range kbds {
+		s.queue(snoopCmd{kbd: kbd})
+	}
+	s.start(ctx)
+	return s, nil
+}
+
+// NewHotplugSnooper will start snooping on all keyboards, both those
+// currently connected and any connected later. Keyboards are attached to as
+// they are connected and detached from (and closed) when they are
+// disconnected, with an ErrDeviceRemoved error reported for each. Snooping
+// stops (and all keyboards are closed) when the context is cancelled. Use
+// WithFilters to only snoop on some keyboards.
+func NewHotplugSnooper(ctx context.Context, opts ...SnooperOption) (*Snooper, error) {
+	o := newSnooperOptions(opts)
+	watchCtx, cancelFunc := context.WithCancel(ctx)
+	devices, err := WatchKeyboardDevices(watchCtx, o.filters...)
+	if err != nil {
+		cancelFunc()
+		return nil, err
+	}
+	s, err := newSnooper(true, o)
+	if err != nil {
+		cancelFunc()
+		return nil, err
+	}
+	go func() {
+		defer cancelFunc()
+		for ev := range devices {
+			switch ev.Type {
+			case DeviceAdded:
+				s.queue(snoopCmd{kbd: ev.Device})
+			case DeviceRemoved:
+				s.queue(snoopCmd{kbd: ev.Device, remove: true})
+			}
+		}
+	}()
+	s.start(ctx)
+	return s, nil
+}
+
+// closedKeys returns an already closed key event channel, for when snooping
+// could not be started
+func closedKeys(err error) <-chan KeyEvent {
+	log.Error().Err(err).Msg("Could not snoop keyboards.")
+	keys := make(chan KeyEvent)
+	close(keys)
+	return keys
+}
+
+// SnoopAllKeyboards will snoop or listen for all key events on all currently
+// connected keyboards. Keyboards are passed in through a channel, see
+// OpenAllKeyboardDevices for an example of opening all connected keyboards.
+// Use NewSnooper to also receive any errors.
+func SnoopAllKeyboards(ctx context.Context, kbds <-chan *KeyboardDevice) <-chan KeyEvent {
+	s, err := NewSnooper(ctx, kbds)
+	if err != nil {
+		return closedKeys(err)
+	}
+	return s.Keys()
+}
+
+// SnoopKeyboard will snoop or listen for all key events on the given keyboard
+// device. Use NewSnooper to also receive any errors.
+func SnoopKeyboard(ctx context.Context, kbd *KeyboardDevice) <-chan KeyEvent {
+	kbds := make(chan *KeyboardDevice, 1)
+	kbds <- kbd
+	close(kbds)
+	s, err := NewSnooper(ctx, kbds)
+	if err != nil {
+		return closedKeys(err)
+	}
+	return s.Keys()
+}
+
+// SnoopHotplugKeyboards will snoop or listen for all key events on all
+// keyboards, both those currently connected and any connected later. See
+// NewHotplugSnooper for details, which should be used to also receive any
+// errors.
+func SnoopHotplugKeyboards(ctx context.Context, filters ...DeviceFilter) (<-chan KeyEvent, error) {
+	s, err := NewHotplugSnooper(ctx, WithFilters(filters...))
+	if err != nil {
+		return nil, err
+	}
+	return s.Keys(), nil
+}
@@ -0,0 +1,31 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/joshuar/gokbd"
+	"github.com/joshuar/gokbd/gokbdtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnooper_Remove(t *testing.T) {
+	fake, err := gokbdtest.NewKeyboard(gokbd.DeviceInfo{DevNode: "/dev/input/event99"})
+	assert.Nil(t, err)
+	s := snoop(t, gokbd.NewKeyboardDevice(fake))
+	fake.Remove()
+	select {
+	case err := <-s.Errors():
+		assert.True(t, errors.Is(err, gokbd.ErrDeviceRemoved))
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for removal")
+	}
+	assert.True(t, errors.Is(s.Wait(), gokbd.ErrDeviceRemoved))
+	assert.True(t, fake.Closed())
+}
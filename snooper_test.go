@@ -0,0 +1,94 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeviceError(t *testing.T) {
+	err := &DeviceError{
+		Device: &KeyboardDevice{DeviceInfo: DeviceInfo{DevNode: "/dev/input/event3"}},
+		Err:    ErrDeviceRemoved,
+	}
+	assert.Equal(t, "/dev/input/event3: device removed", err.Error())
+	assert.True(t, errors.Is(err, ErrDeviceRemoved))
+}
+
+func TestNewSnooper(t *testing.T) {
+	tests := []struct {
+		name    string
+		cancel  bool
+		wantErr error
+	}{
+		{
+			name:    "no keyboards",
+			wantErr: nil,
+		},
+		{
+			name:    "cancelled",
+			cancel:  true,
+			wantErr: context.Canceled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancelFunc := context.WithCancel(context.TODO())
+			defer cancelFunc()
+			if tt.cancel {
+				cancelFunc()
+			}
+			kbds := make(chan *KeyboardDevice)
+			close(kbds)
+			s, err := NewSnooper(ctx, kbds)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantErr, s.Wait())
+			assert.Equal(t, tt.wantErr, s.Err())
+			_, ok := <-s.Keys()
+			assert.False(t, ok)
+			_, ok = <-s.Errors()
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestSnooper_start(t *testing.T) {
+	// With no keyboards, the snooper will be waiting in epoll and must be
+	// woken immediately on cancellation.
+	ctx, cancelFunc := context.WithCancel(context.TODO())
+	s, err := newSnooper(true, snooperOptions{})
+	assert.Nil(t, err)
+	s.start(ctx)
+	assert.Nil(t, s.Err())
+	cancelFunc()
+	select {
+	case <-s.Done():
+	case <-time.After(time.Second):
+		t.Fatal("snooper did not stop after cancellation")
+	}
+	assert.Equal(t, context.Canceled, s.Err())
+	_, ok := <-s.Keys()
+	assert.False(t, ok)
+}
+
+func TestSnooper_reportError(t *testing.T) {
+	s, err := newSnooper(false, snooperOptions{})
+	assert.Nil(t, err)
+	kbd := &KeyboardDevice{DeviceInfo: DeviceInfo{DevNode: "/dev/input/event3"}}
+	s.reportError(kbd, ErrDeviceRemoved)
+	assert.Nil(t, s.Err())
+	go s.finish(context.TODO())
+	err = <-s.Errors()
+	var devErr *DeviceError
+	assert.True(t, errors.As(err, &devErr))
+	assert.Equal(t, kbd, devErr.Device)
+	assert.True(t, errors.Is(s.Wait(), ErrDeviceRemoved))
+}
@@ -0,0 +1,307 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/rs/zerolog/log"
+)
+
+// keyRemap is the spare key that characters are mapped to by
+// StrategyKeymapRemap
+const keyRemap = keyF24
+
+// remapSettle is how long to wait after typing a remapped key before
+// restoring the keymap, so the key is read with the new mapping
+const remapSettle = 50 * time.Millisecond
+
+// TypingStrategy is a way of typing a character on a virtual keyboard
+type TypingStrategy int
+
+const (
+	// StrategyLayout types the character with the keys (and shift level) of
+	// the virtual keyboard's layout. It is always tried first.
+	StrategyLayout TypingStrategy = iota
+	// StrategyUnicodeHex types the character's code point in hex after
+	// Ctrl+Shift+U, followed by a space, as understood by GTK and IBus.
+	StrategyUnicodeHex
+	// StrategyCompose types a dead key or Compose key sequence that produces
+	// the character, from the table set with SetComposeTable. The Compose
+	// key is KEY_COMPOSE unless the layout has Multi_key on another key.
+	StrategyCompose
+	// StrategyKeymapRemap temporarily maps the character to a spare key
+	// with the KeymapRemapper set with SetKeymapRemapper, then types the key
+	// and restores the keymap, as xdotool does.
+	StrategyKeymapRemap
+)
+
+func (s TypingStrategy) String() string {
+	switch s {
+	case StrategyLayout:
+		return "StrategyLayout"
+	case StrategyUnicodeHex:
+		return "StrategyUnicodeHex"
+	case StrategyCompose:
+		return "StrategyCompose"
+	case StrategyKeymapRemap:
+		return "StrategyKeymapRemap"
+	default:
+		return fmt.Sprintf("TypingStrategy(%d)", int(s))
+	}
+}
+
+// KeymapRemapper changes the keymap the system uses for a virtual keyboard,
+// so that StrategyKeymapRemap can type characters that are not in its layout.
+// Key codes are kernel key codes. See XmodmapRemapper for an implementation
+// for X11.
+type KeymapRemapper interface {
+	// Remap will map the key code to the character, on every shift level.
+	Remap(code int, r rune) error
+	// Restore will undo the last Remap of the key code.
+	Restore(code int) error
+}
+
+// typingOptions are the fallbacks a virtual keyboard uses for characters
+// not in its layout and the cadence it types with
+type typingOptions struct {
+	fallbacks    []TypingStrategy
+	composeTable *ComposeTable
+	remapper     KeymapRemapper
+	profile      TypingProfile
+}
+
+// SetFallbacks sets the strategies tried, in order, to type characters that
+// are not in the virtual keyboard's layout. By default there are none, so
+// such characters cannot be typed. The strategies should match what the
+// system supports, for example StrategyUnicodeHex only works in GTK and IBus
+// applications.
+func (u *VirtualKeyboardDevice) SetFallbacks(strategies ...TypingStrategy) {
+	u.typingMu.Lock()
+	defer u.typingMu.Unlock()
+	u.typing.fallbacks = append([]TypingStrategy(nil), strategies...)
+}
+
+// SetComposeTable sets the table StrategyCompose uses to find a sequence for
+// each character. It should match the one the system uses, see
+// LoadLocaleComposeTable.
+func (u *VirtualKeyboardDevice) SetComposeTable(t *ComposeTable) {
+	u.typingMu.Lock()
+	defer u.typingMu.Unlock()
+	u.typing.composeTable = t
+}
+
+// SetKeymapRemapper sets the remapper StrategyKeymapRemap uses to change the
+// keymap
+func (u *VirtualKeyboardDevice) SetKeymapRemapper(r KeymapRemapper) {
+	u.typingMu.Lock()
+	defer u.typingMu.Unlock()
+	u.typing.remapper = r
+}
+
+func (u *VirtualKeyboardDevice) typingOptions() typingOptions {
+	u.typingMu.Lock()
+	defer u.typingMu.Unlock()
+	return u.typing
+}
+
+// TypeRuneStrategy will type the character, as TypeRune, and return the
+// strategy that typed it
+func (u *VirtualKeyboardDevice) TypeRuneStrategy(r rune) (TypingStrategy, error) {
+	return u.typeRune(context.Background(), r)
+}
+
+func (u *VirtualKeyboardDevice) typeRune(ctx context.Context, r rune) (TypingStrategy, error) {
+	if !unicode.In(r, unicode.PrintRanges...) {
+		return StrategyLayout, fmt.Errorf("rune %c (%U) is not a printable character", r, r)
+	}
+	layout := u.Layout()
+	opts := u.typingOptions()
+	keys, err := layoutRuneKeys(layout, r)
+	if err == nil {
+		return StrategyLayout, u.sendKeySequence(ctx, keys)
+	}
+	errs := []error{err}
+	for _, s := range opts.fallbacks {
+		keys, err := strategyKeys(s, layout, opts, r)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s, err))
+			continue
+		}
+		if s == StrategyKeymapRemap {
+			return s, u.typeRemapped(ctx, opts.remapper, r, keys)
+		}
+		return s, u.sendKeySequence(ctx, keys)
+	}
+	return StrategyLayout, errors.Join(errs...)
+}
+
+// TypeStringStrategies will type the string, as TypeString, and return the
+// strategy used for each character typed. If a character cannot be typed,
+// the strategies of those before it are returned with the error.
+func (u *VirtualKeyboardDevice) TypeStringStrategies(str string) ([]TypingStrategy, error) {
+	return u.typeString(context.Background(), str)
+}
+
+func (u *VirtualKeyboardDevice) typeString(ctx context.Context, str string) ([]TypingStrategy, error) {
+	var strategies []TypingStrategy
+	s := strings.NewReader(str)
+	for {
+		r, _, err := s.ReadRune()
+		if err == io.EOF {
+			return strategies, nil
+		}
+		if err != nil {
+			return strategies, err
+		}
+		if err := ctx.Err(); err != nil {
+			return strategies, err
+		}
+		if r == ' ' {
+			if err := u.typeKey(ctx, keySpace, 0); err != nil {
+				return strategies, err
+			}
+			pause(ctx, u.TypingProfile().WordPause)
+			strategies = append(strategies, StrategyLayout)
+			continue
+		}
+		strategy, err := u.typeRune(ctx, r)
+		if err != nil {
+			return strategies, err
+		}
+		strategies = append(strategies, strategy)
+	}
+}
+
+// strategyKeys returns the key events to type the character with a fallback
+// strategy, or an error if the strategy cannot type it
+func strategyKeys(s TypingStrategy, layout Layout, opts typingOptions, r rune) ([]*key, error) {
+	switch s {
+	case StrategyLayout:
+		return layoutRuneKeys(layout, r)
+	case StrategyUnicodeHex:
+		return unicodeHexKeys(layout, r)
+	case StrategyCompose:
+		return composeKeys(layout, opts.composeTable, r)
+	case StrategyKeymapRemap:
+		if opts.remapper == nil {
+			return nil, errors.New("no keymap remapper set")
+		}
+		return chordKeys(keyRemap), nil
+	default:
+		return nil, errors.New("unknown strategy")
+	}
+}
+
+// layoutRuneKeys returns the key events to type the character with the keys
+// of the layout
+func layoutRuneKeys(layout Layout, r rune) ([]*key, error) {
+	keyCode, level := layout.Code(r)
+	if keyCode == 0 {
+		return nil, fmt.Errorf("rune %c (%U) is not in %s layout", r, r, layout.Name())
+	}
+	if level&LevelFive != 0 {
+		return nil, fmt.Errorf("rune %c (%U) is on the %s level of the %s layout, which cannot be typed", r, r, level, layout.Name())
+	}
+	return levelKeys(keyCode, level), nil
+}
+
+// unicodeHexKeys returns the key events to type Ctrl+Shift+U, the code point
+// of the character in hex and a space to finish. U is the key with a u in
+// the layout, which is KEY_U for QWERTY layouts.
+func unicodeHexKeys(layout Layout, r rune) ([]*key, error) {
+	u, _ := layout.Code('u')
+	if u == 0 {
+		u = keyU
+	}
+	keys := chordKeys(u, keyLeftCtrl, keyLeftShift)
+	for _, d := range fmt.Sprintf("%x", r) {
+		digit, err := layoutRuneKeys(layout, d)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, digit...)
+	}
+	return append(keys, chordKeys(keySpace)...), nil
+}
+
+// composeKeys returns the key events to type the shortest sequence in the
+// table that produces the character and can be typed with the layout
+func composeKeys(layout Layout, table *ComposeTable, r rune) ([]*key, error) {
+	if table == nil {
+		return nil, errors.New("no compose table set")
+	}
+	for _, seq := range table.sequences(string(r)) {
+		var keys []*key
+		for _, sym := range seq {
+			symKeys, ok := composeSymKeys(layout, sym)
+			if !ok {
+				keys = nil
+				break
+			}
+			keys = append(keys, symKeys...)
+		}
+		if keys != nil {
+			return keys, nil
+		}
+	}
+	return nil, fmt.Errorf("no compose sequence for rune %c (%U) can be typed with %s layout", r, r, layout.Name())
+}
+
+// composeSymKeys returns the key events to type a step of a compose sequence
+func composeSymKeys(layout Layout, sym composeSym) ([]*key, bool) {
+	if sym.name == "" {
+		keys, err := layoutRuneKeys(layout, sym.r)
+		return keys, err == nil
+	}
+	if code, level, ok := deadKeyCode(layout, sym.name); ok {
+		return levelKeys(code, level), true
+	}
+	if sym.name == "Multi_key" {
+		return chordKeys(keyCompose), true
+	}
+	return nil, false
+}
+
+// deadKeyCode returns the key code and shift level of the dead key with the
+// given keysym name in the layout, preferring the lowest level then the
+// lowest code
+func deadKeyCode(layout Layout, name string) (int, Level, bool) {
+	for level := Level(0); level < LevelFive; level++ {
+		for code := 1; code < keyCnt; code++ {
+			if layout.DeadKey(code, level) == name {
+				return code, level, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// typeRemapped will map the character to the spare key, type it and restore
+// the keymap
+func (u *VirtualKeyboardDevice) typeRemapped(ctx context.Context, remapper KeymapRemapper, r rune, keys []*key) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := remapper.Remap(keyRemap, r); err != nil {
+		return fmt.Errorf("could not remap key: %w", err)
+	}
+	err := u.sendKeySequence(ctx, keys)
+	time.Sleep(remapSettle)
+	if restoreErr := remapper.Restore(keyRemap); restoreErr != nil {
+		log.Error().Err(restoreErr).Msg("Could not restore remapped key.")
+		if err == nil {
+			err = fmt.Errorf("could not restore remapped key: %w", restoreErr)
+		}
+	}
+	return err
+}
@@ -0,0 +1,153 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joshuar/gokbd"
+	"github.com/joshuar/gokbd/gokbdtest"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRemapper records the characters remapped to each key
+type fakeRemapper struct {
+	remapped map[int]rune
+	calls    []string
+}
+
+func (f *fakeRemapper) Remap(code int, r rune) error {
+	f.remapped[code] = r
+	f.calls = append(f.calls, "remap "+string(r))
+	return nil
+}
+
+func (f *fakeRemapper) Restore(code int) error {
+	delete(f.remapped, code)
+	f.calls = append(f.calls, "restore")
+	return nil
+}
+
+func TestVirtualKeyboardDevice_Fallbacks(t *testing.T) {
+	table, err := gokbd.ParseCompose(strings.NewReader(`<Multi_key> <apostrophe> <e> : "é"`))
+	assert.Nil(t, err)
+	v, sink := newVirtualKeyboard(t)
+
+	// without fallbacks, only the layout is used
+	_, err = v.TypeStringStrategies("é")
+	assert.NotNil(t, err)
+
+	remapper := &fakeRemapper{remapped: make(map[int]rune)}
+	v.SetComposeTable(table)
+	v.SetKeymapRemapper(remapper)
+	v.SetFallbacks(gokbd.StrategyCompose, gokbd.StrategyKeymapRemap, gokbd.StrategyUnicodeHex)
+	got, err := v.TypeStringStrategies("e é🦍")
+	assert.Nil(t, err)
+	assert.Equal(t, []gokbd.TypingStrategy{
+		gokbd.StrategyLayout,
+		gokbd.StrategyLayout,
+		gokbd.StrategyCompose,
+		gokbd.StrategyKeymapRemap,
+	}, got)
+	assert.Equal(t, pressed(gokbd.KeyE, gokbd.KeySpace, gokbd.KeyCompose, gokbd.KeyApostrophe, gokbd.KeyE, gokbd.KeyF24), sink.Pressed())
+	assert.Equal(t, []string{"remap 🦍", "restore"}, remapper.calls)
+	assert.Empty(t, remapper.remapped)
+
+	sink.Reset()
+	v.SetFallbacks(gokbd.StrategyUnicodeHex)
+	strategy, err := v.TypeRuneStrategy('é')
+	assert.Nil(t, err)
+	assert.Equal(t, gokbd.StrategyUnicodeHex, strategy)
+	// Ctrl+Shift+U e 9 Space
+	assert.Equal(t, pressed(gokbd.KeyLeftCtrl, gokbd.KeyLeftShift, gokbd.KeyU, gokbd.KeyE, gokbd.Key9, gokbd.KeySpace), sink.Pressed())
+}
+
+func TestVirtualKeyboardDevice_TypeStringContext(t *testing.T) {
+	v, sink := newVirtualKeyboard(t,
+		gokbd.WithTypingProfile(gokbd.TypingProfile{Delay: 10 * time.Millisecond}))
+
+	// a cancelled context types nothing
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	n, err := v.TypeStringContext(ctx, "hi")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Zero(t, n)
+	assert.ErrorIs(t, v.TypeRuneContext(ctx, 'h'), context.Canceled)
+	assert.ErrorIs(t, v.TypeKeyContext(ctx, gokbd.KeyA, false), context.Canceled)
+	assert.ErrorIs(t, v.TypeChordContext(ctx, gokbd.KeyLeftCtrl, gokbd.KeyA), context.Canceled)
+	assert.ErrorIs(t, v.SendShortcutContext(ctx, "ctrl+a"), context.Canceled)
+	assert.Empty(t, sink.Events())
+
+	// typing stops part way and can be resumed from the count returned
+	const str = "hi hi hi hi hi hi hi hi"
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	n, err = v.TypeStringContext(ctx, str)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Greater(t, n, 0)
+	assert.Less(t, n, len(str))
+	assert.Equal(t, len(sink.Pressed()), n)
+	rest, err := v.TypeStringContext(context.Background(), str[n:])
+	assert.Nil(t, err)
+	assert.Equal(t, len(str)-n, rest)
+
+	w, want := newVirtualKeyboard(t)
+	assert.Nil(t, w.TypeString(str))
+	assert.Equal(t, want.Pressed(), sink.Pressed())
+}
+
+func TestVirtualKeyboardDevice_TypeRuneContext(t *testing.T) {
+	v, sink := newVirtualKeyboard(t,
+		gokbd.WithTypingProfile(gokbd.TypingProfile{Dwell: time.Minute}))
+
+	// cancelling while Shift is held cuts the dwell short, and the character
+	// is finished with Shift released
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	assert.Nil(t, v.TypeRuneContext(ctx, 'A'))
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, []gokbd.InputEvent{
+		keyEvent(gokbd.KeyLeftShift, 1), syncEvent(),
+		keyEvent(gokbd.KeyA, 1), syncEvent(),
+		keyEvent(gokbd.KeyA, 0), syncEvent(),
+		keyEvent(gokbd.KeyLeftShift, 0), syncEvent(),
+	}, keyEvents(sink))
+}
+
+// cancelSink cancels a context once events have been written to it
+type cancelSink struct {
+	*gokbdtest.Sink
+	cancel context.CancelFunc
+}
+
+func (s cancelSink) WriteEvents(events ...gokbd.InputEvent) error {
+	defer s.cancel()
+	return s.Sink.WriteEvents(events...)
+}
+
+func TestVirtualKeyboardDevice_TypeRuneContextRemapped(t *testing.T) {
+	sink, err := gokbdtest.NewSink()
+	assert.Nil(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	v := newVirtualKeyboardWithSink(t, cancelSink{Sink: sink, cancel: cancel})
+	remapper := &fakeRemapper{remapped: make(map[int]rune)}
+	v.SetKeymapRemapper(remapper)
+	v.SetFallbacks(gokbd.StrategyKeymapRemap)
+
+	// cancelling once the remapped key is typed still waits for the key to
+	// be read before the keymap is restored, so the character is finished
+	start := time.Now()
+	assert.Nil(t, v.TypeRuneContext(ctx, '🦍'))
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	assert.Equal(t, []int{int(gokbd.KeyF24)}, sink.Pressed())
+	assert.Equal(t, []string{"remap 🦍", "restore"}, remapper.calls)
+	assert.Empty(t, remapper.remapped)
+}
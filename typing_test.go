@@ -0,0 +1,104 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// pressedCodes returns the codes of the key presses in the key events
+func pressedCodes(keys []*key) []int {
+	var codes []int
+	for _, k := range keys {
+		if k.keyType == evKey && k.value == 1 {
+			codes = append(codes, k.keyCode)
+		}
+	}
+	return codes
+}
+
+func TestTypingStrategy_String(t *testing.T) {
+	assert.Equal(t, "StrategyLayout", StrategyLayout.String())
+	assert.Equal(t, "StrategyUnicodeHex", StrategyUnicodeHex.String())
+	assert.Equal(t, "StrategyCompose", StrategyCompose.String())
+	assert.Equal(t, "StrategyKeymapRemap", StrategyKeymapRemap.String())
+	assert.Equal(t, "TypingStrategy(9)", TypingStrategy(9).String())
+}
+
+func Test_unicodeHexKeys(t *testing.T) {
+	dvorak, err := LayoutByName("dvorak")
+	assert.Nil(t, err)
+	tests := []struct {
+		name    string
+		layout  Layout
+		r       rune
+		want    []int
+		wantErr bool
+	}{
+		{
+			name:   "emoji",
+			layout: DefaultLayout(),
+			r:      '🦍',
+			// Ctrl, Shift, U, 1 f 9 8 d, Space
+			want: []int{keyLeftCtrl, keyLeftShift, keyU, 2, 33, 10, 9, 32, keySpace},
+		},
+		{
+			name:   "u moved by layout",
+			layout: dvorak,
+			r:      'é',
+			// Ctrl, Shift, U (on the F key), e 9, Space
+			want: []int{keyLeftCtrl, keyLeftShift, 33, 32, 10, keySpace},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := unicodeHexKeys(tt.layout, tt.r)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, pressedCodes(got))
+		})
+	}
+}
+
+func Test_composeKeys(t *testing.T) {
+	table, err := LoadComposeTable(filepath.Join("testdata", "compose", "Compose"))
+	assert.Nil(t, err)
+	qwertz, err := LayoutByName("de")
+	assert.Nil(t, err)
+	tests := []struct {
+		name    string
+		layout  Layout
+		table   *ComposeTable
+		r       rune
+		want    []int
+		wantErr bool
+	}{
+		{name: "dead key", layout: qwertz, table: table, r: 'á', want: []int{13, 30}},
+		{name: "shortest sequence", layout: qwertz, table: table, r: 'é', want: []int{13, 18}},
+		{name: "compose key", layout: qwertz, table: table, r: '©', want: []int{keyCompose, 24, 46}},
+		{name: "dead key not in layout", layout: DefaultLayout(), table: table, r: 'á', wantErr: true},
+		{name: "falls back to compose key", layout: DefaultLayout(), table: table, r: 'é', want: []int{keyCompose, 40, 18}},
+		{name: "no sequence", layout: qwertz, table: table, r: '🦍', wantErr: true},
+		{name: "no table", layout: qwertz, r: 'é', wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := composeKeys(tt.layout, tt.table, tt.r)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, pressedCodes(got))
+		})
+	}
+}
@@ -0,0 +1,263 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"unsafe"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sys/unix"
+)
+
+const watchMask = unix.IN_CREATE | unix.IN_ATTRIB | unix.IN_MOVED_TO |
+	unix.IN_DELETE | unix.IN_MOVED_FROM
+
+// DeviceEventType represents whether a keyboard device was connected or
+// disconnected
+type DeviceEventType int
+
+const (
+	// DeviceAdded indicates a keyboard has been connected (or was already
+	// connected when watching started)
+	DeviceAdded DeviceEventType = iota
+	// DeviceRemoved indicates a previously added keyboard has been
+	// disconnected
+	DeviceRemoved
+)
+
+func (t DeviceEventType) String() string {
+	switch t {
+	case DeviceAdded:
+		return "DeviceAdded"
+	case DeviceRemoved:
+		return "DeviceRemoved"
+	default:
+		return fmt.Sprintf("DeviceEventType(%d)", int(t))
+	}
+}
+
+// DeviceEvent represents a keyboard being connected or disconnected. Path is
+// the device node path and Device is the opened keyboard device. For a
+// DeviceRemoved event, Device is the same value that was passed out with the
+// matching DeviceAdded event.
+type DeviceEvent struct {
+	Device *KeyboardDevice
+	Path   string
+	Type   DeviceEventType
+}
+
+type inotifyEvent struct {
+	name string
+	mask uint32
+}
+
+// parseInotifyEvents will decode the raw inotify events read from an inotify
+// file descriptor
+func parseInotifyEvents(buf []byte) []inotifyEvent {
+	var events []inotifyEvent
+	offset := 0
+	for offset+unix.SizeofInotifyEvent <= len(buf) {
+		raw := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+		nameStart := offset + unix.SizeofInotifyEvent
+		nameEnd := nameStart + int(raw.Len)
+		if nameEnd > len(buf) {
+			break
+		}
+		events = append(events, inotifyEvent{
+			name: string(bytes.TrimRight(buf[nameStart:nameEnd], "\x00")),
+			mask: raw.Mask,
+		})
+		offset = nameEnd
+	}
+	return events
+}
+
+// deviceWatcher keeps track of the device nodes seen by WatchKeyboardDevices:
+// the keyboards passed out, until they are removed or closed, and the nodes
+// that are not keyboards (or do not match the filters), until they are
+// deleted, so they are not opened again each time their attributes change
+type deviceWatcher struct {
+	filters  []DeviceFilter
+	open     func(path string, filters []DeviceFilter) (*KeyboardDevice, error)
+	tracked  map[string]*KeyboardDevice
+	rejected map[string]bool
+	mu       sync.Mutex
+}
+
+func newDeviceWatcher(filters []DeviceFilter) *deviceWatcher {
+	return &deviceWatcher{
+		filters:  filters,
+		open:     openIfKeyboard,
+		tracked:  make(map[string]*KeyboardDevice),
+		rejected: make(map[string]bool),
+	}
+}
+
+// add will open the device at the given path, returning it if it is a
+// keyboard that matches the filters and is not already tracked. It is
+// tracked until it is removed or closed.
+func (w *deviceWatcher) add(path string) *KeyboardDevice {
+	w.mu.Lock()
+	seen := w.tracked[path] != nil || w.rejected[path]
+	w.mu.Unlock()
+	if seen {
+		return nil
+	}
+	kbd, err := w.open(path, w.filters)
+	if err != nil {
+		// Permissions on new device nodes are usually not set until shortly
+		// after creation, the device will be retried when its attributes
+		// change.
+		log.Debug().Caller().Err(err).
+			Msgf("Could not open device %s.", path)
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if kbd == nil {
+		w.rejected[path] = true
+		return nil
+	}
+	w.tracked[path] = kbd
+	kbd.onClose = func() {
+		w.forget(path, kbd)
+	}
+	return kbd
+}
+
+// remove will stop tracking the device node at the given path, returning the
+// keyboard opened from it, if any
+func (w *deviceWatcher) remove(path string) *KeyboardDevice {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.rejected, path)
+	kbd := w.tracked[path]
+	delete(w.tracked, path)
+	return kbd
+}
+
+// forget will stop tracking the keyboard, if it is still tracked at the given
+// path, so it is added again if its device node changes
+func (w *deviceWatcher) forget(path string, kbd *KeyboardDevice) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.tracked[path] == kbd {
+		delete(w.tracked, path)
+	}
+}
+
+// WatchKeyboardDevices will watch for keyboards being connected and
+// disconnected, passing out a DeviceEvent for each through the returned
+// channel. Keyboards already connected are passed out as DeviceAdded events
+// first. The channel is closed when the context is cancelled. The watcher
+// never closes any devices itself, this is left to the receiver (typically
+// after a DeviceRemoved event or once the channel is closed). A keyboard
+// closed before it is disconnected (for example, after an error reading it)
+// is no longer tracked, and is passed out again if its device node changes.
+// If any filters are given, only keyboards matching at least one of them are
+// passed out.
+func WatchKeyboardDevices(ctx context.Context, filters ...DeviceFilter) (<-chan DeviceEvent, error) {
+	fd, err := unix.InotifyInit1(unix.IN_NONBLOCK | unix.IN_CLOEXEC)
+	if err != nil {
+		return nil, fmt.Errorf("could not initialise inotify: %w", err)
+	}
+	if _, err := unix.InotifyAddWatch(fd, devicePath, watchMask); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("could not watch %s: %w", devicePath, err)
+	}
+	watcher := os.NewFile(uintptr(fd), "inotify")
+
+	events := make(chan DeviceEvent)
+	go func() {
+		<-ctx.Done()
+		watcher.Close()
+	}()
+	go func() {
+		defer close(events)
+		w := newDeviceWatcher(filters)
+		send := func(ev DeviceEvent) bool {
+			select {
+			case <-ctx.Done():
+				return false
+			case events <- ev:
+				return true
+			}
+		}
+		add := func(path string) bool {
+			kbd := w.add(path)
+			if kbd == nil {
+				return true
+			}
+			if !send(DeviceEvent{Type: DeviceAdded, Path: path, Device: kbd}) {
+				kbd.Close()
+				return false
+			}
+			return true
+		}
+		remove := func(path string) bool {
+			kbd := w.remove(path)
+			if kbd == nil {
+				return true
+			}
+			return send(DeviceEvent{Type: DeviceRemoved, Path: path, Device: kbd})
+		}
+
+		for _, path := range findAllInputDevices() {
+			if !add(path) {
+				return
+			}
+		}
+		buf := make([]byte, 4096)
+		for {
+			n, err := watcher.Read(buf)
+			if err != nil {
+				if !errors.Is(err, os.ErrClosed) {
+					log.Error().Caller().Err(err).
+						Msg("Could not read device changes.")
+				}
+				return
+			}
+			for _, ev := range parseInotifyEvents(buf[:n]) {
+				if !eventDeviceRegexp.MatchString(ev.name) {
+					continue
+				}
+				path := filepath.Join(devicePath, ev.name)
+				ok := true
+				switch {
+				case ev.mask&(unix.IN_CREATE|unix.IN_ATTRIB|unix.IN_MOVED_TO) != 0:
+					ok = add(path)
+				case ev.mask&(unix.IN_DELETE|unix.IN_MOVED_FROM) != 0:
+					ok = remove(path)
+				}
+				if !ok {
+					return
+				}
+			}
+		}
+	}()
+	return events, nil
+}
+
+// openIfKeyboard will open the device at the given path, returning it only if
+// it is a keyboard that matches the filters
+func openIfKeyboard(path string, filters []DeviceFilter) (*KeyboardDevice, error) {
+	kbd, err := OpenKeyboardDevice(path)
+	if err != nil {
+		return nil, err
+	}
+	if !kbd.isKeyboard() || !matchFilters(kbd, filters) {
+		kbd.Close()
+		return nil, nil
+	}
+	return kbd, nil
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+func rawInotifyEvent(mask uint32, name string, padTo int) []byte {
+	nameBytes := make([]byte, padTo)
+	copy(nameBytes, name)
+	buf := make([]byte, unix.SizeofInotifyEvent)
+	binary.LittleEndian.PutUint32(buf[4:], mask)
+	binary.LittleEndian.PutUint32(buf[12:], uint32(len(nameBytes)))
+	return append(buf, nameBytes...)
+}
+
+func Test_parseInotifyEvents(t *testing.T) {
+	type args struct {
+		buf []byte
+	}
+	tests := []struct {
+		name string
+		args args
+		want []inotifyEvent
+	}{
+		{
+			name: "single event",
+			args: args{buf: rawInotifyEvent(unix.IN_CREATE, "event3", 16)},
+			want: []inotifyEvent{{name: "event3", mask: unix.IN_CREATE}},
+		},
+		{
+			name: "multiple events",
+			args: args{buf: append(
+				rawInotifyEvent(unix.IN_CREATE, "event3", 16),
+				rawInotifyEvent(unix.IN_DELETE, "event12", 16)...)},
+			want: []inotifyEvent{
+				{name: "event3", mask: unix.IN_CREATE},
+				{name: "event12", mask: unix.IN_DELETE},
+			},
+		},
+		{
+			name: "truncated event",
+			args: args{buf: rawInotifyEvent(unix.IN_CREATE, "event3", 16)[:20]},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseInotifyEvents(tt.args.buf); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseInotifyEvents() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeviceEventType_String(t *testing.T) {
+	tests := []struct {
+		name string
+		t    DeviceEventType
+		want string
+	}{
+		{
+			name: "added",
+			t:    DeviceAdded,
+			want: "DeviceAdded",
+		},
+		{
+			name: "removed",
+			t:    DeviceRemoved,
+			want: "DeviceRemoved",
+		},
+		{
+			name: "unknown",
+			t:    DeviceEventType(5),
+			want: "DeviceEventType(5)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.t.String(); got != tt.want {
+				t.Errorf("DeviceEventType.String() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// closeSource is an EventSource that can only be closed
+type closeSource struct {
+	EventSource
+}
+
+func (closeSource) Close() error { return nil }
+
+func Test_deviceWatcher(t *testing.T) {
+	const (
+		kbdPath   = "/dev/input/event3"
+		mousePath = "/dev/input/event4"
+	)
+	opened := make(map[string]int)
+	w := newDeviceWatcher(nil)
+	w.open = func(path string, _ []DeviceFilter) (*KeyboardDevice, error) {
+		opened[path]++
+		if path == mousePath {
+			return nil, nil
+		}
+		return &KeyboardDevice{src: closeSource{}}, nil
+	}
+
+	// a keyboard is only passed out once while it is tracked
+	kbd := w.add(kbdPath)
+	assert.NotNil(t, kbd)
+	assert.Nil(t, w.add(kbdPath))
+	assert.Equal(t, 1, opened[kbdPath])
+
+	// closing the keyboard stops it being tracked, so it is added again
+	kbd.Close()
+	again := w.add(kbdPath)
+	assert.NotNil(t, again)
+	assert.Equal(t, 2, opened[kbdPath])
+	// forgetting the old keyboard leaves the new one tracked
+	w.forget(kbdPath, kbd)
+	assert.Equal(t, again, w.remove(kbdPath))
+	assert.Nil(t, w.remove(kbdPath))
+
+	// other devices are not opened again until they are removed
+	assert.Nil(t, w.add(mousePath))
+	assert.Nil(t, w.add(mousePath))
+	assert.Equal(t, 1, opened[mousePath])
+	assert.Nil(t, w.remove(mousePath))
+	assert.Nil(t, w.add(mousePath))
+	assert.Equal(t, 2, opened[mousePath])
+}
@@ -0,0 +1,378 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//go:generate go run ./internal/cmd/genkeysyms -o keysyms.go
+
+// DefaultXKBDir is where the XKB data files are usually installed. The
+// XKB_CONFIG_ROOT environment variable overrides it, as it does for
+// libxkbcommon.
+const DefaultXKBDir = "/usr/share/X11/xkb"
+
+// xkbKeycodeOffset is the difference between XKB keycodes and the kernel key
+// codes they are derived from
+const xkbKeycodeOffset = 8
+
+// xkbMaxIncludeDepth limits how deeply includes are followed, to catch loops
+const xkbMaxIncludeDepth = 16
+
+// xkbIncludeRegexp matches each part of an include, for example the
+// "+fr(azerty)" of "pc+fr(azerty)". The operator sets how it is merged (+ to
+// override, | to augment) and the optional :N is the group it is put in.
+var xkbIncludeRegexp = regexp.MustCompile(`([+|]?)([^+|():]+)(?:\(([^)]*)\))?(?::(\d+))?`)
+
+// xkbControlRunes are the keysyms without a Unicode equivalent in keysymdef.h
+// that still produce a rune
+var xkbControlRunes = map[string]rune{
+	"BackSpace":    '\b',
+	"Tab":          '\t',
+	"ISO_Left_Tab": '\t',
+	"Return":       '\n',
+}
+
+// LoadXKBLayout will load the layout with the given name, for example "de" or
+// "fr(azerty)", from the files in the symbols directory under dir. If dir is
+// empty, the XKB_CONFIG_ROOT environment variable or DefaultXKBDir is used.
+// As with setxkbmap, the layout is combined with the "pc" symbols and the key
+// names are mapped to key codes with the "evdev" keycodes.
+func LoadXKBLayout(dir, name string) (Layout, error) {
+	l := newXKBLoader(dir)
+	keycodes, err := l.keycodes(xkbIncludeSection("evdev+aliases(qwerty)"))
+	if err != nil {
+		return nil, fmt.Errorf("could not load keycodes: %w", err)
+	}
+	keys := make(map[string][]string)
+	if err := l.applySymbols(xkbIncludeSection("pc+"+name), keys, 0); err != nil {
+		return nil, fmt.Errorf("could not load layout %s: %w", name, err)
+	}
+	return newXKBLayout(name, keycodes, keys), nil
+}
+
+// LoadXKBKeymap will load a layout from a compiled XKB keymap file, such as
+// one written by "xkbcomp $DISPLAY keymap.xkb". Any files the keymap
+// includes are loaded from under the XKB_CONFIG_ROOT environment variable or
+// DefaultXKBDir.
+func LoadXKBKeymap(path string) (Layout, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open keymap: %w", err)
+	}
+	defer f.Close()
+	return ParseXKBKeymap(f)
+}
+
+// ParseXKBKeymap will read a layout from a compiled XKB keymap, the format
+// written by xkbcomp. The layout is named after the keymap's xkb_symbols
+// section.
+func ParseXKBKeymap(r io.Reader) (Layout, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("could not read keymap: %w", err)
+	}
+	sections, err := parseXKB(string(src))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse keymap: %w", err)
+	}
+	var keycodes, symbols *xkbSection
+	for i := 0; i < len(sections); i++ {
+		sec := sections[i]
+		if sec.kind == "xkb_keymap" {
+			sections = append(sections, sec.sections...)
+			continue
+		}
+		switch {
+		case sec.kind == "xkb_keycodes" && keycodes == nil:
+			keycodes = sec
+		case sec.kind == "xkb_symbols" && symbols == nil:
+			symbols = sec
+		}
+	}
+	if keycodes == nil || symbols == nil {
+		return nil, errors.New("keymap has no xkb_keycodes or xkb_symbols section")
+	}
+	l := newXKBLoader("")
+	codes, err := l.keycodes(keycodes)
+	if err != nil {
+		return nil, fmt.Errorf("could not load keycodes: %w", err)
+	}
+	keys := make(map[string][]string)
+	if err := l.applySymbols(symbols, keys, 0); err != nil {
+		return nil, fmt.Errorf("could not load symbols: %w", err)
+	}
+	return newXKBLayout(symbols.name, codes, keys), nil
+}
+
+// xkbLevelModifiers are the keysyms that select a shift level
+var xkbLevelModifiers = map[string]Level{
+	"ISO_Level3_Shift": LevelThree,
+	"ISO_Level5_Shift": LevelFive,
+}
+
+// newXKBLayout will build a layout from the keysyms for each key name
+func newXKBLayout(name string, keycodes func(string) int, keys map[string][]string) Layout {
+	runes := make(map[int][]rune)
+	dead := make(map[int][]string)
+	modifiers := make(map[int]Level)
+	for keyName, syms := range keys {
+		code := keycodes(keyName) - xkbKeycodeOffset
+		if code <= 0 {
+			continue
+		}
+		for len(syms) > 0 && syms[len(syms)-1] == "NoSymbol" {
+			syms = syms[:len(syms)-1]
+		}
+		if len(syms) == 0 {
+			continue
+		}
+		if level, ok := xkbLevelModifiers[syms[0]]; ok {
+			modifiers[code] = level
+			continue
+		}
+		levels := make([]rune, len(syms))
+		deadLevels := make([]string, len(syms))
+		var found, foundDead bool
+		for i, sym := range syms {
+			levels[i] = keysymRune(sym)
+			found = found || levels[i] != 0
+			if levels[i] == 0 && isDeadKeysym(sym) {
+				deadLevels[i] = sym
+				foundDead = true
+			}
+		}
+		if found {
+			runes[code] = levels
+		}
+		if foundDead {
+			dead[code] = deadLevels
+		}
+	}
+	return newLevelLayout(name, runes, dead, modifiers)
+}
+
+// keysymRune returns the character a keysym produces, or 0 if it produces
+// none
+func keysymRune(sym string) rune {
+	if r, ok := keysymRunes[sym]; ok {
+		return r
+	}
+	if r, ok := xkbControlRunes[sym]; ok {
+		return r
+	}
+	switch {
+	case len(sym) > 1 && sym[0] == 'U':
+		// Unicode keysyms, for example U20AC
+		if v, err := strconv.ParseUint(sym[1:], 16, 32); err == nil {
+			return rune(v)
+		}
+	case strings.HasPrefix(sym, "0x"):
+		v, err := strconv.ParseUint(sym[2:], 16, 32)
+		if err != nil {
+			return 0
+		}
+		switch {
+		case v >= 0x01000000 && v <= 0x0110ffff:
+			return rune(v - 0x01000000)
+		case v >= 0x20 && v <= 0x7e, v >= 0xa0 && v <= 0xff:
+			// Latin-1 keysyms have the same value as the character.
+			return rune(v)
+		}
+	}
+	return 0
+}
+
+// xkbLoader loads and caches the files under an XKB data directory
+type xkbLoader struct {
+	dir   string
+	files map[string][]*xkbSection
+}
+
+func newXKBLoader(dir string) *xkbLoader {
+	if dir == "" {
+		dir = os.Getenv("XKB_CONFIG_ROOT")
+	}
+	if dir == "" {
+		dir = DefaultXKBDir
+	}
+	return &xkbLoader{
+		dir:   dir,
+		files: make(map[string][]*xkbSection),
+	}
+}
+
+// section will return the section of the given kind and name from a file in
+// the directory for that kind. With no name, the default section (or the
+// first if none is marked default) is returned.
+func (l *xkbLoader) section(kind, subdir, file, name string) (*xkbSection, error) {
+	path := filepath.Join(l.dir, subdir, filepath.Clean("/"+file))
+	sections, ok := l.files[path]
+	if !ok {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if sections, err = parseXKB(string(src)); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		l.files[path] = sections
+	}
+	var first *xkbSection
+	for _, sec := range sections {
+		if sec.kind != kind {
+			continue
+		}
+		switch {
+		case name != "" && sec.name == name, name == "" && sec.isDefault:
+			return sec, nil
+		case first == nil:
+			first = sec
+		}
+	}
+	if name == "" && first != nil {
+		return first, nil
+	}
+	return nil, fmt.Errorf("%s: no %s section %q", path, kind, name)
+}
+
+// xkbIncludeSection returns a section that only includes the given spec
+func xkbIncludeSection(spec string) *xkbSection {
+	return &xkbSection{stmts: []xkbStmt{{kind: xkbStmtInclude, name: spec}}}
+}
+
+// xkbInclude is a single part of an include statement
+type xkbInclude struct {
+	mode    xkbMergeMode
+	file    string
+	section string
+}
+
+// parseXKBInclude will split an include such as "pc+fr(azerty)" into its
+// parts, dropping any that are for groups other than the first
+func parseXKBInclude(spec string, mode xkbMergeMode) []xkbInclude {
+	var includes []xkbInclude
+	for i, m := range xkbIncludeRegexp.FindAllStringSubmatch(spec, -1) {
+		inc := xkbInclude{mode: mode, file: m[2], section: m[3]}
+		if i > 0 {
+			switch m[1] {
+			case "|":
+				inc.mode = xkbMergeAugment
+			default:
+				inc.mode = xkbMergeOverride
+			}
+		}
+		if m[4] != "" && m[4] != "1" {
+			continue
+		}
+		includes = append(includes, inc)
+	}
+	return includes
+}
+
+// keycodes will build a lookup of key names to XKB keycodes from an
+// xkb_keycodes section (and any it includes)
+func (l *xkbLoader) keycodes(sec *xkbSection) (func(string) int, error) {
+	codes := make(map[string]int)
+	aliases := make(map[string]string)
+	var apply func(sec *xkbSection, depth int) error
+	apply = func(sec *xkbSection, depth int) error {
+		if depth > xkbMaxIncludeDepth {
+			return errors.New("includes nested too deeply")
+		}
+		for _, stmt := range sec.stmts {
+			switch stmt.kind {
+			case xkbStmtInclude:
+				for _, inc := range parseXKBInclude(stmt.name, stmt.mode) {
+					included, err := l.section("xkb_keycodes", "keycodes", inc.file, inc.section)
+					if err != nil {
+						return err
+					}
+					if err := apply(included, depth+1); err != nil {
+						return err
+					}
+				}
+			case xkbStmtKeycode:
+				codes[stmt.name] = stmt.code
+			case xkbStmtAlias:
+				aliases[stmt.name] = stmt.alias
+			}
+		}
+		return nil
+	}
+	if err := apply(sec, 0); err != nil {
+		return nil, err
+	}
+	return func(name string) int {
+		if code, ok := codes[name]; ok {
+			return code
+		}
+		return codes[aliases[name]]
+	}, nil
+}
+
+// applySymbols will merge the keysyms defined by an xkb_symbols section (and
+// any it includes) into keys
+func (l *xkbLoader) applySymbols(sec *xkbSection, keys map[string][]string, depth int) error {
+	if depth > xkbMaxIncludeDepth {
+		return errors.New("includes nested too deeply")
+	}
+	for _, stmt := range sec.stmts {
+		switch stmt.kind {
+		case xkbStmtInclude:
+			for _, inc := range parseXKBInclude(stmt.name, stmt.mode) {
+				included, err := l.section("xkb_symbols", "symbols", inc.file, inc.section)
+				if err != nil {
+					return err
+				}
+				incKeys := make(map[string][]string)
+				if err := l.applySymbols(included, incKeys, depth+1); err != nil {
+					return err
+				}
+				for name, syms := range incKeys {
+					mergeXKBKey(keys, name, syms, inc.mode)
+				}
+			}
+		case xkbStmtKey:
+			mergeXKBKey(keys, stmt.name, stmt.syms, stmt.mode)
+		}
+	}
+	return nil
+}
+
+// mergeXKBKey will merge the keysyms for a key into keys. Levels with no
+// keysym (NoSymbol) never replace a level that has one, unless the whole key
+// is being replaced.
+func mergeXKBKey(keys map[string][]string, name string, syms []string, mode xkbMergeMode) {
+	old, ok := keys[name]
+	if !ok || mode == xkbMergeReplace {
+		keys[name] = syms
+		return
+	}
+	merged := make([]string, len(old))
+	copy(merged, old)
+	for i, sym := range syms {
+		if i >= len(merged) {
+			merged = append(merged, sym)
+			continue
+		}
+		if sym == "NoSymbol" {
+			continue
+		}
+		if mode == xkbMergeOverride || merged[i] == "NoSymbol" {
+			merged[i] = sym
+		}
+	}
+	keys[name] = merged
+}
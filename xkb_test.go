@@ -0,0 +1,296 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Key codes of the keys in the test XKB data
+const (
+	testKey1     = 2
+	testKey2     = 3
+	testKeyTab   = 15
+	testKeyQ     = 16
+	testKeyW     = 17
+	testKeyE     = 18
+	testKeyA     = 30
+	testKeyGrave = 41
+	testKeyBksl  = 43
+	testKeyZ     = 44
+	testKeyX     = 45
+	testKey102nd = 86
+)
+
+func TestLoadXKBLayout(t *testing.T) {
+	type key struct {
+		code  int
+		level Level
+	}
+	tests := []struct {
+		name          string
+		layout        string
+		want          map[key]rune
+		wantDead      map[key]string
+		wantModifiers map[int]Level
+		wantErr       bool
+	}{
+		{
+			name:   "default variant",
+			layout: "xx",
+			want: map[key]rune{
+				{code: testKeyQ}:                       'a',
+				{code: testKeyA}:                       'q',
+				{code: testKeyA, level: LevelShift}:    'Q',
+				{code: testKeyZ}:                       'w',
+				{code: testKeyW}:                       'z',
+				{code: testKey1, level: LevelShift}:    '!',
+				{code: testKey2}:                       'é',
+				{code: testKeyBksl}:                    '€',
+				{code: testKeyBksl, level: LevelShift}: 'µ',
+				{code: testKeyGrave}:                   '`',
+				{code: testKey102nd}:                   '<',
+				{code: testKeyTab, level: LevelShift}:  '\t',
+				{code: 57}:                             ' ',
+				{code: 57, level: LevelShift}:          ' ',
+				// the levels of latin not redefined by xx are kept
+				{code: testKeyQ, level: LevelThree}:              '@',
+				{code: testKeyGrave, level: LevelThree}:          '`',
+				{code: testKeyX, level: LevelThree}:              '»',
+				{code: testKeyX, level: LevelShift | LevelThree}: '>',
+				{code: testKeyX, level: LevelFive}:               'x',
+			},
+			wantModifiers: map[int]Level{
+				keyRightAlt: LevelThree,
+				testKeyX:    0,
+			},
+		},
+		{
+			name:   "level five",
+			layout: "xx(level5)",
+			want: map[key]rune{
+				{code: testKey2, level: LevelFive}:              '₂',
+				{code: testKey2, level: LevelFive | LevelShift}: '½',
+				{code: testKey2, level: LevelThree}:             '²',
+			},
+			wantModifiers: map[int]Level{
+				keyRightAlt:  LevelThree,
+				testKey102nd: LevelFive,
+			},
+		},
+		{
+			name:   "dead keys",
+			layout: "xx(dead)",
+			want: map[key]rune{
+				{code: testKeyE, level: LevelThree}: '[',
+			},
+			wantDead: map[key]string{
+				{code: testKeyE}:                    "dead_circumflex",
+				{code: testKeyE, level: LevelShift}: "dead_diaeresis",
+				{code: testKeyE, level: LevelThree}: "",
+				{code: keyRightAlt}:                 "Multi_key",
+				{code: testKeyQ}:                    "",
+				{code: testKeyQ, level: LevelFive}:  "",
+			},
+			wantModifiers: map[int]Level{
+				keyRightAlt: 0,
+			},
+		},
+		{
+			name:   "override and augment",
+			layout: "xx(euro)",
+			want: map[key]rune{
+				{code: testKey1}:                    '€',
+				{code: testKey1, level: LevelShift}: '!',
+				{code: testKey2}:                    'é',
+				{code: testKeyX}:                    'x',
+			},
+		},
+		{
+			name:   "augmented include",
+			layout: "xx(plain)",
+			want: map[key]rune{
+				{code: testKey1}:     '1',
+				{code: testKeyQ}:     'q',
+				{code: testKeyGrave}: '`',
+			},
+		},
+		{
+			name:   "other groups ignored",
+			layout: "xx(grouped)",
+			want: map[key]rune{
+				{code: testKeyQ}: 'a',
+				{code: testKeyZ}: 'w',
+			},
+		},
+		{
+			name:    "include loop",
+			layout:  "xx(loop)",
+			wantErr: true,
+		},
+		{
+			name:    "missing include",
+			layout:  "xx(missing)",
+			wantErr: true,
+		},
+		{
+			name:    "missing variant",
+			layout:  "xx(nothere)",
+			wantErr: true,
+		},
+		{
+			name:    "missing layout",
+			layout:  "../../keymap.xkb",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadXKBLayout(filepath.Join("testdata", "xkb"), tt.layout)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.layout, got.Name())
+			for k, r := range tt.want {
+				assert.Equal(t, r, got.Rune(k.code, k.level), "code %d level %s", k.code, k.level)
+			}
+			for k, d := range tt.wantDead {
+				assert.Equal(t, d, got.DeadKey(k.code, k.level), "code %d level %s", k.code, k.level)
+			}
+			for code, level := range tt.wantModifiers {
+				assert.Equal(t, level, got.Modifier(code), "code %d", code)
+			}
+		})
+	}
+}
+
+func TestLoadXKBLayout_system(t *testing.T) {
+	if _, err := os.Stat(filepath.Join(DefaultXKBDir, "symbols", "us")); err != nil {
+		t.Skip("no XKB data installed")
+	}
+	// The built-in layouts should agree with the XKB definitions.
+	for name, xkbName := range map[string]string{
+		"us":      "us",
+		"gb":      "gb",
+		"de":      "de",
+		"fr":      "fr",
+		"dvorak":  "us(dvorak)",
+		"colemak": "us(colemak)",
+	} {
+		builtin, err := LayoutByName(name)
+		assert.Nil(t, err)
+		got, err := LoadXKBLayout(DefaultXKBDir, xkbName)
+		if !assert.Nil(t, err) {
+			continue
+		}
+		// Only compare the levels the built-in layout has.
+		keys := builtin.(*levelLayout).keys
+		dead := builtin.(*levelLayout).dead
+		for _, code := range layoutCodes() {
+			for level := Level(0); int(level) < len(keys[code]) || int(level) < len(dead[code]); level++ {
+				if r := builtin.Rune(code, level); r != 0 {
+					assert.Equal(t, r, got.Rune(code, level), "%s: code %d level %s", name, code, level)
+				}
+				if d := builtin.DeadKey(code, level); d != "" {
+					assert.Equal(t, d, got.DeadKey(code, level), "%s: code %d level %s", name, code, level)
+				}
+			}
+		}
+		if builtin.Modifier(keyRightAlt) != 0 {
+			assert.Equal(t, LevelThree, got.Modifier(keyRightAlt), name)
+		}
+	}
+}
+
+func TestLoadXKBKeymap(t *testing.T) {
+	got, err := LoadXKBKeymap(filepath.Join("testdata", "keymap.xkb"))
+	assert.Nil(t, err)
+	assert.Equal(t, "pc+fr+inet(evdev)", got.Name())
+	assert.Equal(t, '&', got.Rune(testKey1, 0))
+	assert.Equal(t, '1', got.Rune(testKey1, LevelShift))
+	assert.Equal(t, '¹', got.Rune(testKey1, LevelThree))
+	assert.Equal(t, 'a', got.Rune(testKeyQ, 0))
+	assert.Equal(t, 'Q', got.Rune(testKeyA, LevelShift))
+	code, level := got.Code('1')
+	assert.Equal(t, testKey1, code)
+	assert.Equal(t, LevelShift, level)
+	assert.Equal(t, rune(0), got.Rune(1, 0))
+
+	_, err = LoadXKBKeymap(filepath.Join("testdata", "nothere.xkb"))
+	assert.NotNil(t, err)
+}
+
+func TestParseXKBKeymap(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{
+			name: "no symbols",
+			src:  `xkb_keymap { xkb_keycodes { <AE01> = 10; }; };`,
+		},
+		{
+			name: "unterminated string",
+			src:  `xkb_keymap { xkb_symbols "pc { }; };`,
+		},
+		{
+			name: "unterminated comment",
+			src:  `xkb_keymap { /* };`,
+		},
+		{
+			name: "unterminated section",
+			src:  `xkb_keymap { xkb_symbols { key <AE01> { [ 1 ] };`,
+		},
+		{
+			name: "bad keycode",
+			src:  `xkb_keycodes { <AE01> = one; };`,
+		},
+		{
+			name: "bad keysym",
+			src:  `xkb_symbols { key <AE01> { [ "1" ] }; };`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseXKBKeymap(strings.NewReader(tt.src))
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func Test_keysymRune(t *testing.T) {
+	tests := []struct {
+		sym  string
+		want rune
+	}{
+		{sym: "a", want: 'a'},
+		{sym: "1", want: '1'},
+		{sym: "eacute", want: 'é'},
+		{sym: "EuroSign", want: '€'},
+		{sym: "Greek_OMEGA", want: 'Ω'},
+		{sym: "Return", want: '\n'},
+		{sym: "U2022", want: '•'},
+		{sym: "0x10020ac", want: '€'},
+		{sym: "0xe9", want: 'é'},
+		{sym: "0xff0d"},
+		{sym: "Shift_L"},
+		{sym: "dead_acute"},
+		{sym: "NoSymbol"},
+		{sym: "Uxyz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sym, func(t *testing.T) {
+			assert.Equal(t, tt.want, keysymRune(tt.sym))
+		})
+	}
+}
@@ -0,0 +1,490 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// This file holds a parser for the parts of the XKB text format gokbd needs:
+// the xkb_keycodes and xkb_symbols sections, either on their own (as in the
+// files under /usr/share/X11/xkb) or inside an xkb_keymap (as written by
+// xkbcomp). Everything else (types, compat, geometry, actions, etc.) is
+// skipped.
+
+type xkbTokenKind int
+
+const (
+	xkbEOF xkbTokenKind = iota
+	xkbIdent
+	xkbString
+	xkbKeyName
+	xkbPunct
+)
+
+type xkbToken struct {
+	kind xkbTokenKind
+	text string
+	line int
+}
+
+func (t xkbToken) is(kind xkbTokenKind, text string) bool {
+	return t.kind == kind && t.text == text
+}
+
+func (t xkbToken) String() string {
+	switch t.kind {
+	case xkbEOF:
+		return "end of file"
+	case xkbKeyName:
+		return "<" + t.text + ">"
+	default:
+		return strconv.Quote(t.text)
+	}
+}
+
+// lexXKB will split XKB source into tokens, dropping comments
+func lexXKB(src string) ([]xkbToken, error) {
+	var tokens []xkbToken
+	line := 1
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '#' || strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated comment", line)
+			}
+			line += strings.Count(src[i:i+2+end], "\n")
+			i += end + 4
+		case c == '"':
+			end := strings.IndexByte(src[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated string", line)
+			}
+			tokens = append(tokens, xkbToken{kind: xkbString, text: src[i+1 : i+1+end], line: line})
+			i += end + 2
+		case c == '<':
+			end := strings.IndexByte(src[i+1:], '>')
+			if end < 0 || strings.ContainsAny(src[i+1:i+1+end], " \t\n") {
+				return nil, fmt.Errorf("line %d: bad key name", line)
+			}
+			tokens = append(tokens, xkbToken{kind: xkbKeyName, text: src[i+1 : i+1+end], line: line})
+			i += end + 2
+		case isXKBIdentChar(c):
+			start := i
+			for i < len(src) && isXKBIdentChar(src[i]) {
+				i++
+			}
+			tokens = append(tokens, xkbToken{kind: xkbIdent, text: src[start:i], line: line})
+		default:
+			tokens = append(tokens, xkbToken{kind: xkbPunct, text: string(c), line: line})
+			i++
+		}
+	}
+	return append(tokens, xkbToken{kind: xkbEOF, line: line}), nil
+}
+
+func isXKBIdentChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// xkbMergeMode is how definitions are combined with those already made
+type xkbMergeMode int
+
+const (
+	xkbMergeOverride xkbMergeMode = iota
+	xkbMergeAugment
+	xkbMergeReplace
+)
+
+var xkbMergeModes = map[string]xkbMergeMode{
+	"include":  xkbMergeOverride,
+	"override": xkbMergeOverride,
+	"augment":  xkbMergeAugment,
+	"replace":  xkbMergeReplace,
+}
+
+type xkbStmtKind int
+
+const (
+	xkbStmtInclude xkbStmtKind = iota
+	xkbStmtKey
+	xkbStmtKeycode
+	xkbStmtAlias
+	xkbStmtName
+)
+
+// xkbStmt is a statement in an xkb_keycodes or xkb_symbols section
+type xkbStmt struct {
+	kind xkbStmtKind
+	mode xkbMergeMode
+	// name is the include spec, key name or group name
+	name string
+	// alias is the key an alias refers to
+	alias string
+	// code is the keycode given to a key name
+	code int
+	// syms are the keysyms for each shift level of the first group of a key
+	syms []string
+}
+
+// xkbSection is an xkb_keycodes, xkb_symbols or xkb_keymap section
+type xkbSection struct {
+	kind      string
+	name      string
+	isDefault bool
+	stmts     []xkbStmt
+	sections  []*xkbSection
+}
+
+type xkbParser struct {
+	tokens []xkbToken
+	pos    int
+}
+
+// parseXKB will parse the sections in XKB source
+func parseXKB(src string) ([]*xkbSection, error) {
+	tokens, err := lexXKB(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &xkbParser{tokens: tokens}
+	var sections []*xkbSection
+	for p.peek().kind != xkbEOF {
+		sec, err := p.section()
+		if err != nil {
+			return nil, err
+		}
+		sections = append(sections, sec)
+	}
+	return sections, nil
+}
+
+func (p *xkbParser) peek() xkbToken {
+	return p.tokens[p.pos]
+}
+
+func (p *xkbParser) next() xkbToken {
+	t := p.tokens[p.pos]
+	if t.kind != xkbEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *xkbParser) errorf(t xkbToken, format string, args ...any) error {
+	return fmt.Errorf("line %d: %s", t.line, fmt.Sprintf(format, args...))
+}
+
+func (p *xkbParser) expect(kind xkbTokenKind, text string) error {
+	if t := p.next(); !t.is(kind, text) {
+		return p.errorf(t, "expected %q, found %s", text, t)
+	}
+	return nil
+}
+
+// section parses: [flags...] xkb_kind ["name"] { body };
+func (p *xkbParser) section() (*xkbSection, error) {
+	sec := &xkbSection{}
+	for {
+		t := p.next()
+		if t.kind != xkbIdent {
+			return nil, p.errorf(t, "expected section, found %s", t)
+		}
+		if t.text == "default" {
+			sec.isDefault = true
+		}
+		if strings.HasPrefix(t.text, "xkb_") {
+			sec.kind = t.text
+			break
+		}
+	}
+	if p.peek().kind == xkbString {
+		sec.name = p.next().text
+	}
+	if err := p.expect(xkbPunct, "{"); err != nil {
+		return nil, err
+	}
+	var err error
+	switch sec.kind {
+	case "xkb_keymap":
+		for p.peek().kind == xkbIdent {
+			var child *xkbSection
+			if child, err = p.section(); err != nil {
+				return nil, err
+			}
+			sec.sections = append(sec.sections, child)
+		}
+		err = p.expect(xkbPunct, "}")
+	case "xkb_keycodes", "xkb_symbols":
+		sec.stmts, err = p.body()
+	default:
+		err = p.skipBlock()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().is(xkbPunct, ";") {
+		p.next()
+	}
+	return sec, nil
+}
+
+// skipBlock skips past the closing brace of the block already opened
+func (p *xkbParser) skipBlock() error {
+	depth := 1
+	for depth > 0 {
+		t := p.next()
+		switch {
+		case t.kind == xkbEOF:
+			return p.errorf(t, "unexpected end of file")
+		case t.is(xkbPunct, "{"):
+			depth++
+		case t.is(xkbPunct, "}"):
+			depth--
+		}
+	}
+	return nil
+}
+
+// skipStatement skips to the end of the current statement, the ; or the end
+// of the enclosing block
+func (p *xkbParser) skipStatement() error {
+	depth := 0
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == xkbEOF:
+			return p.errorf(t, "unexpected end of file")
+		case depth == 0 && t.is(xkbPunct, ";"):
+			p.next()
+			return nil
+		case depth == 0 && t.is(xkbPunct, "}"):
+			return nil
+		case t.is(xkbPunct, "{"), t.is(xkbPunct, "["), t.is(xkbPunct, "("):
+			depth++
+		case t.is(xkbPunct, "}"), t.is(xkbPunct, "]"), t.is(xkbPunct, ")"):
+			depth--
+		}
+		p.next()
+	}
+}
+
+// body parses the statements of an xkb_keycodes or xkb_symbols section up to
+// and including the closing brace
+func (p *xkbParser) body() ([]xkbStmt, error) {
+	var stmts []xkbStmt
+	for {
+		t := p.peek()
+		if t.is(xkbPunct, "}") {
+			p.next()
+			return stmts, nil
+		}
+		if t.kind == xkbEOF {
+			return nil, p.errorf(t, "unexpected end of file")
+		}
+		mode := xkbMergeOverride
+		if m, ok := xkbMergeModes[t.text]; ok && t.kind == xkbIdent {
+			p.next()
+			mode = m
+			if p.peek().kind == xkbString {
+				stmts = append(stmts, xkbStmt{kind: xkbStmtInclude, mode: mode, name: p.next().text})
+				if p.peek().is(xkbPunct, ";") {
+					p.next()
+				}
+				continue
+			}
+			t = p.peek()
+		}
+		stmt, ok, err := p.statement(t)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			stmt.mode = mode
+			stmts = append(stmts, stmt)
+		}
+	}
+}
+
+// statement parses a single statement, returning false if it is not one that
+// is needed
+func (p *xkbParser) statement(t xkbToken) (xkbStmt, bool, error) {
+	var stmt xkbStmt
+	switch {
+	case t.kind == xkbKeyName:
+		// <NAME> = code;
+		p.next()
+		if err := p.expect(xkbPunct, "="); err != nil {
+			return stmt, false, err
+		}
+		v := p.next()
+		code, err := strconv.ParseInt(v.text, 0, 32)
+		if err != nil {
+			return stmt, false, p.errorf(v, "bad keycode %s", v)
+		}
+		stmt = xkbStmt{kind: xkbStmtKeycode, name: t.text, code: int(code)}
+	case t.is(xkbIdent, "alias"):
+		// alias <NAME> = <NAME>;
+		p.next()
+		name, eq, alias := p.next(), p.next(), p.next()
+		if name.kind != xkbKeyName || !eq.is(xkbPunct, "=") || alias.kind != xkbKeyName {
+			return stmt, false, p.errorf(t, "bad alias")
+		}
+		stmt = xkbStmt{kind: xkbStmtAlias, name: name.text, alias: alias.text}
+	case t.is(xkbIdent, "key") && p.tokens[p.pos+1].kind == xkbKeyName:
+		// key <NAME> { ... };
+		p.next()
+		stmt = xkbStmt{kind: xkbStmtKey, name: p.next().text}
+		syms, err := p.keyBody()
+		if err != nil {
+			return stmt, false, err
+		}
+		stmt.syms = syms
+	case t.is(xkbIdent, "name"):
+		// name[Group1] = "...";
+		p.next()
+		group, err := p.groupIndex()
+		if err != nil {
+			return stmt, false, err
+		}
+		if err := p.expect(xkbPunct, "="); err != nil {
+			return stmt, false, err
+		}
+		v := p.next()
+		if group != 1 || v.kind != xkbString {
+			return stmt, false, p.skipStatement()
+		}
+		stmt = xkbStmt{kind: xkbStmtName, name: v.text}
+	default:
+		return stmt, false, p.skipStatement()
+	}
+	if err := p.skipStatement(); err != nil {
+		return stmt, false, err
+	}
+	return stmt, true, nil
+}
+
+// groupIndex parses an optional [GroupN], returning 1 if there is none
+func (p *xkbParser) groupIndex() (int, error) {
+	if !p.peek().is(xkbPunct, "[") {
+		return 1, nil
+	}
+	p.next()
+	t := p.next()
+	group, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(t.text), "group"))
+	if err != nil {
+		return 0, p.errorf(t, "bad group %s", t)
+	}
+	return group, p.expect(xkbPunct, "]")
+}
+
+// keyBody parses the { ... } of a key statement, returning the keysyms of the
+// first group
+func (p *xkbParser) keyBody() ([]string, error) {
+	if err := p.expect(xkbPunct, "{"); err != nil {
+		return nil, err
+	}
+	var syms []string
+	group := 0
+	for {
+		t := p.peek()
+		switch {
+		case t.is(xkbPunct, "}"):
+			p.next()
+			return syms, nil
+		case t.is(xkbPunct, ","):
+			p.next()
+		case t.is(xkbPunct, "["):
+			// an unlabelled list of keysyms is the next group
+			group++
+			list, err := p.symList()
+			if err != nil {
+				return nil, err
+			}
+			if group == 1 {
+				syms = list
+			}
+		case t.is(xkbIdent, "symbols"):
+			p.next()
+			g, err := p.groupIndex()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(xkbPunct, "="); err != nil {
+				return nil, err
+			}
+			list, err := p.symList()
+			if err != nil {
+				return nil, err
+			}
+			if g == 1 {
+				syms = list
+			}
+		default:
+			if err := p.skipItem(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// skipItem skips a key property such as type= or actions[Group1]=
+func (p *xkbParser) skipItem() error {
+	depth := 0
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == xkbEOF:
+			return p.errorf(t, "unexpected end of file")
+		case depth == 0 && (t.is(xkbPunct, ",") || t.is(xkbPunct, "}")):
+			return nil
+		case t.is(xkbPunct, "{"), t.is(xkbPunct, "["), t.is(xkbPunct, "("):
+			depth++
+		case t.is(xkbPunct, "}"), t.is(xkbPunct, "]"), t.is(xkbPunct, ")"):
+			depth--
+		}
+		p.next()
+	}
+}
+
+// symList parses [ sym, sym, ... ]. A level with more than one keysym, { a, b },
+// is treated as having none.
+func (p *xkbParser) symList() ([]string, error) {
+	if err := p.expect(xkbPunct, "["); err != nil {
+		return nil, err
+	}
+	var syms []string
+	for {
+		t := p.next()
+		switch {
+		case t.is(xkbPunct, "]"):
+			return syms, nil
+		case t.is(xkbPunct, ","):
+		case t.kind == xkbIdent:
+			syms = append(syms, t.text)
+		case t.is(xkbPunct, "{"):
+			if err := p.skipBlock(); err != nil {
+				return nil, err
+			}
+			syms = append(syms, "NoSymbol")
+		default:
+			return nil, p.errorf(t, "bad keysym %s", t)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// XmodmapRemapper is a KeymapRemapper that changes the X server's keymap with
+// the xmodmap command. As the X server has a single keymap, the change applies
+// to every keyboard, but only for X11 (and XWayland) applications.
+type XmodmapRemapper struct {
+	// Command is the path of the xmodmap command, found in $PATH if empty.
+	Command string
+	saved   map[int]string
+	mu      sync.Mutex
+}
+
+// Remap will map the key code to the character, saving the keysyms it had so
+// they can be restored
+func (x *XmodmapRemapper) Remap(code int, r rune) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	keycode := code + xkbKeycodeOffset
+	orig, err := x.keycode(keycode)
+	if err != nil {
+		return err
+	}
+	if err := x.run("-e", fmt.Sprintf("keycode %d = U%04X U%04X", keycode, r, r)); err != nil {
+		return err
+	}
+	if x.saved == nil {
+		x.saved = make(map[int]string)
+	}
+	x.saved[code] = orig
+	return nil
+}
+
+// Restore will map the key code back to the keysyms it had before Remap
+func (x *XmodmapRemapper) Restore(code int) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	orig, ok := x.saved[code]
+	if !ok {
+		return fmt.Errorf("key code %d has not been remapped", code)
+	}
+	if err := x.run("-e", orig); err != nil {
+		return err
+	}
+	delete(x.saved, code)
+	return nil
+}
+
+// keycode returns the xmodmap expression for the current keysyms of the X
+// keycode, for example "keycode 202 = F24 NoSymbol F24"
+func (x *XmodmapRemapper) keycode(keycode int) (string, error) {
+	out, err := x.output("-pke")
+	if err != nil {
+		return "", err
+	}
+	prefix := fmt.Sprintf("keycode %d =", keycode)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.Join(strings.Fields(scanner.Text()), " ")
+		if line == prefix || strings.HasPrefix(line, prefix+" ") {
+			return line, nil
+		}
+	}
+	// Keycodes with no keysyms may be left out.
+	return prefix, nil
+}
+
+func (x *XmodmapRemapper) command() string {
+	if x.Command == "" {
+		return "xmodmap"
+	}
+	return x.Command
+}
+
+func (x *XmodmapRemapper) run(args ...string) error {
+	_, err := x.output(args...)
+	return err
+}
+
+func (x *XmodmapRemapper) output(args ...string) ([]byte, error) {
+	out, err := exec.Command(x.command(), args...).Output()
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr) && len(exitErr.Stderr) > 0:
+		return nil, fmt.Errorf("xmodmap %s failed: %w: %s", strings.Join(args, " "), err, bytes.TrimSpace(exitErr.Stderr))
+	case err != nil:
+		return nil, fmt.Errorf("xmodmap %s failed: %w", strings.Join(args, " "), err)
+	}
+	return out, nil
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2023 Joshua Rich <joshua.rich@gmail.com>
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package gokbd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeXmodmap writes a script that logs its arguments and prints a keymap for
+// -pke, returning its path and the path of the log
+func fakeXmodmap(t *testing.T) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	script := filepath.Join(dir, "xmodmap")
+	log := filepath.Join(dir, "log")
+	err := os.WriteFile(script, []byte(`#!/bin/sh
+echo "$@" >> `+log+`
+case "$1" in
+-pke)
+	echo "keycode  38 = a A a A"
+	echo "keycode 202 = F24 NoSymbol F24"
+	;;
+-e)
+	case "$2" in *U0000*) echo "bad keysym" >&2; exit 1;; esac
+	;;
+esac
+`), 0o700)
+	assert.Nil(t, err)
+	return script, log
+}
+
+func TestXmodmapRemapper(t *testing.T) {
+	script, log := fakeXmodmap(t)
+	x := &XmodmapRemapper{Command: script}
+	assert.Nil(t, x.Remap(keyF24, '🦍'))
+	assert.Nil(t, x.Restore(keyF24))
+	// a keycode missing from the keymap is cleared on restore
+	assert.Nil(t, x.Remap(keyF24+1, 'é'))
+	assert.Nil(t, x.Restore(keyF24+1))
+	assert.NotNil(t, x.Restore(keyF24))
+	err := x.Remap(keyF24, 0)
+	assert.ErrorContains(t, err, "bad keysym")
+
+	got, err := os.ReadFile(log)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"-pke",
+		"-e keycode 202 = U1F98D U1F98D",
+		"-e keycode 202 = F24 NoSymbol F24",
+		"-pke",
+		"-e keycode 203 = U00E9 U00E9",
+		"-e keycode 203 =",
+		"-pke",
+		"-e keycode 202 = U0000 U0000",
+	}, strings.Split(strings.TrimSpace(string(got)), "\n"))
+
+	x = &XmodmapRemapper{Command: filepath.Join(t.TempDir(), "missing")}
+	assert.NotNil(t, x.Remap(keyF24, 'é'))
+}